@@ -0,0 +1,119 @@
+package gdom
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+)
+
+// largeDoc makes a spring like beans doc with n beans of 8 properties each
+func largeDoc(n int) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, n*512))
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<beans>\n")
+	for i := 0; i < n; i++ {
+		id := strconv.Itoa(i)
+		buf.WriteString(`    <bean id="bean` + id + `" class="org.example.Bean` + id + `">` + "\n")
+		for j := 0; j < 8; j++ {
+			p := strconv.Itoa(j)
+			buf.WriteString(`        <property name="p` + p + `" value="v` + id + "." + p + `"/>` + "\n")
+		}
+		buf.WriteString("        <!-- bean " + id + " -->\n    </bean>\n")
+	}
+	buf.WriteString("</beans>")
+	return buf.Bytes()
+}
+
+var benchDoc = largeDoc(5000)
+
+func BenchmarkParse(b *testing.B) {
+	b.SetBytes(int64(len(benchDoc)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := ParseBytes(benchDoc)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParsePositions(b *testing.B) {
+	b.SetBytes(int64(len(benchDoc)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_, err := ParseWithOptions(bytes.NewReader(benchDoc), &ParseOptions{Positions: true})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWalk(b *testing.B) {
+	d, _ := ParseBytes(benchDoc)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cnt := 0
+		for range d.All() {
+			cnt++
+		}
+		if cnt == 0 {
+			b.Fatal("nothing walked")
+		}
+	}
+}
+
+func BenchmarkIndex(b *testing.B) {
+	d, _ := ParseBytes(benchDoc)
+	last := d.Root().LastChild()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if last.Index() < 0 {
+			b.Fatal("wrong index")
+		}
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	d, _ := ParseBytes(benchDoc)
+	b.SetBytes(int64(len(benchDoc)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := d.Write(io.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseLazy(b *testing.B) {
+	b.SetBytes(int64(len(benchDoc)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d, err := ParseLazy(benchDoc)
+		if err != nil {
+			b.Fatal(err)
+		}
+		// read one branch
+		if len(d.Root().AllEles()[0].AllEles()) != 8 {
+			b.Fatal("wrong branch")
+		}
+	}
+}
+
+func BenchmarkPersistentEdit(b *testing.B) {
+	d, _ := ParseBytes(benchDoc)
+	p := d.Persistent()
+	name := NewName("", "value")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		np, err := p.SetAttr(Path{2*(i%5000) + 1, 1}, name, strconv.Itoa(i))
+		if err != nil {
+			b.Fatal(err)
+		}
+		p = np
+	}
+}
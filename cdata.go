@@ -0,0 +1,40 @@
+package gdom
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// make a *CharData written as a CDATA section, a CDATA section is only merged
+// with the CDATA sections next to it, not with text
+func NewCDATA(ctt string) *CharData {
+	c := NewCharData(ctt)
+	c.IsCDATA = true
+	return c
+}
+
+// TextOption chooses how AddCharDataStr adds its text
+type TextOption int
+
+const (
+	// add the text as a CDATA section
+	AsCDATA TextOption = iota + 1
+)
+
+// add s as a CDATA section, like AddCharDataStr(s, AsCDATA)
+func (e *Ele) AddCDATAStr(s string) {
+	e.AddCharDataStr(s, AsCDATA)
+}
+
+// writeCDATA writes v as a CDATA section, a ]]> in v ends the section after
+// the ]] and starts a new one
+func writeCDATA(w io.Writer, v string) error {
+	_, err := io.WriteString(w, "<![CDATA["+strings.ReplaceAll(v, "]]>", "]]]]><![CDATA[>")+"]]>")
+	return err
+}
+
+// isCDATA reports whether the source b of a CharData token is a CDATA section
+func isCDATA(b []byte) bool {
+	return bytes.HasPrefix(b, []byte("<![CDATA["))
+}
@@ -0,0 +1,39 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestCDATA(t *testing.T) {
+	src := `<script>if (a &lt; b) <![CDATA[x < y && z]]></script>`
+	d, err := ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ToString() != src {
+		t.Error("round trip:", d.ToString())
+	}
+	r := d.Root()
+	if cd := r.LastChild().(*CharData); !cd.IsCDATA || cd.V != "x < y && z" {
+		t.Error("cdata:", cd.V)
+	}
+	if r.Text() != "if (a < b) x < y && z" {
+		t.Error("text:", r.Text())
+	}
+	r.AddCharDataStr(" a]]>b", AsCDATA)
+	r.AddCharDataStr(" <")
+	if r.getNodes().Len() != 3 {
+		t.Error("cdata merged with text")
+	}
+	want := `<script>if (a &lt; b) <![CDATA[x < y && z a]]]]><![CDATA[>b]]> &lt;</script>`
+	if d.ToString() != want {
+		t.Error("write:", d.ToString())
+	}
+	re, _ := ParseString(want)
+	if re.Root().Text() != r.Text() {
+		t.Error("split cdata:", re.Root().Text())
+	}
+	if d.Persistent().Doc().ToString() != want {
+		t.Error("persistent lost the cdata")
+	}
+}
@@ -0,0 +1,20 @@
+// Package compat helps moving code written against the v1 gdom API, where every
+// node embedded its *list.Element, to v2. the nodes of v2 keep Next and Prev,
+// returning a *gdom.Link, for the code that only walked the siblings. see MIGRATION.md
+package compat
+
+import (
+	gdom "github.com/xxy84/gdom/v2"
+)
+
+// Link is the *list.Element a v1 node embedded
+type Link = gdom.Link
+
+// Of returns the Link of n, nil if n is nil. it makes the v1 code that got the
+// *list.Element of any node, like a gdom.Node, compile: n.Next() becomes Of(n).Next()
+func Of(n gdom.Node) *Link {
+	if n == nil {
+		return nil
+	}
+	return &Link{Value: n}
+}
@@ -0,0 +1,37 @@
+package compat
+
+import (
+	"testing"
+
+	gdom "github.com/xxy84/gdom/v2"
+)
+
+func TestLink(t *testing.T) {
+	d, _ := gdom.ParseString(`<p><a/><b/></p>`)
+	a := d.Root().ElesByStrName("", "a")[0]
+	b := Of(a).Next().Value.(*gdom.Ele)
+	if b.Name.Local != "b" || Of(b).Next() != nil || Of(b).Prev().Value != gdom.Node(a) {
+		t.Error("link failed")
+	}
+}
+
+// the way v1 code walked the siblings, it compiles as it is
+func TestV1Siblings(t *testing.T) {
+	d, _ := gdom.ParseString(`<p><a/>t<!--c--><b/></p>`)
+	a := d.Root().ElesByStrName("", "a")[0]
+	s := ""
+	for x := a.Next(); x != nil; x = x.Next() {
+		switch n := x.Value.(type) {
+		case *gdom.CharData:
+			s += n.V
+		case *gdom.Comment:
+			s += n.V
+		case *gdom.Ele:
+			s += n.Name.Local
+		}
+	}
+	b := d.Root().ElesByStrName("", "b")[0]
+	if s != "tcb" || b.Prev().Prev().Value.(*gdom.CharData).V != "t" || a.Prev() != nil {
+		t.Error("v1 siblings:", s)
+	}
+}
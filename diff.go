@@ -0,0 +1,424 @@
+package gdom
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ChangeType is the kind of a Change found by Diff
+type ChangeType int
+
+const (
+	ChangeInsert ChangeType = iota
+	ChangeDelete
+	ChangeMove
+	ChangeAttrAdd
+	ChangeAttrRemove
+	ChangeAttrModify
+	ChangeAttrOrder
+	ChangeText
+)
+
+var changeTypeNames = [...]string{
+	ChangeInsert:     "insert",
+	ChangeDelete:     "delete",
+	ChangeMove:       "move",
+	ChangeAttrAdd:    "attr-add",
+	ChangeAttrRemove: "attr-remove",
+	ChangeAttrModify: "attr-modify",
+	ChangeAttrOrder:  "attr-order",
+	ChangeText:       "text",
+}
+
+func (t ChangeType) String() string {
+	if t < 0 || int(t) >= len(changeTypeNames) {
+		return "unknown"
+	}
+	return changeTypeNames[t]
+}
+
+// Change is one difference between two Docs.
+// Path locates the node in the old doc, or in the new doc for ChangeInsert.
+type Change struct {
+	Type ChangeType
+	Path string
+	// the node in the old doc, nil for ChangeInsert
+	Old Node
+	// the node in the new doc, nil for ChangeDelete
+	New Node
+	// the attr name for the ChangeAttr* types
+	Attr Name
+	// old and new value of the attr or the text
+	OldValue string
+	NewValue string
+	// position among the compared siblings, -1 if not known
+	OldIndex int
+	NewIndex int
+}
+
+// DiffOptions controls how Diff matches and compares nodes, nil means the zero value
+type DiffOptions struct {
+	// Keys are attributes used to match sibling elements, like "bean@id" or "*@id".
+	// elements without a key are matched by name and position
+	Keys []string
+	// ignore CharData holding only whitespace, and surrounding whitespace of text
+	IgnoreWhitespace bool
+	IgnoreComments   bool
+	IgnoreAttrOrder  bool
+}
+
+// Diff returns the changes turning a into b
+func Diff(a, b *Doc, opts *DiffOptions) []Change {
+	df := newDiffer(opts)
+	df.diffChildren(a, b, "")
+	return df.changes
+}
+
+// DiffEle returns the changes turning the element a into b, b may have another name.
+// the paths start at a like Diff makes them for a root element
+func DiffEle(a, b *Ele, opts *DiffOptions) []Change {
+	df := newDiffer(opts)
+	path := rootPath(df.keys, a)
+	if a.Name != b.Name {
+		df.add(Change{Type: ChangeDelete, Path: path, Old: a, OldIndex: -1, NewIndex: -1})
+		df.add(Change{Type: ChangeInsert, Path: rootPath(df.keys, b), New: b, OldIndex: -1, NewIndex: -1})
+		return df.changes
+	}
+	df.diffEle(a, b, path)
+	return df.changes
+}
+
+// Report returns a human readable text of the changes, one change per line
+func Report(changes []Change) string {
+	buf := bytes.NewBuffer(make([]byte, 0, 64*len(changes)))
+	for _, c := range changes {
+		buf.WriteString(c.String())
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeInsert:
+		return fmt.Sprintf("+ %s: %s", c.Path, nodeString(c.New))
+	case ChangeDelete:
+		return fmt.Sprintf("- %s: %s", c.Path, nodeString(c.Old))
+	case ChangeMove:
+		return fmt.Sprintf("~ %s: moved from %d to %d", c.Path, c.OldIndex, c.NewIndex)
+	case ChangeAttrAdd:
+		return fmt.Sprintf("+ %s/@%s: %q", c.Path, nameString(c.Attr), c.NewValue)
+	case ChangeAttrRemove:
+		return fmt.Sprintf("- %s/@%s: %q", c.Path, nameString(c.Attr), c.OldValue)
+	case ChangeAttrModify:
+		return fmt.Sprintf("* %s/@%s: %q -> %q", c.Path, nameString(c.Attr), c.OldValue, c.NewValue)
+	case ChangeAttrOrder:
+		return fmt.Sprintf("~ %s: attribute order changed", c.Path)
+	case ChangeText:
+		return fmt.Sprintf("* %s/text(): %q -> %q", c.Path, c.OldValue, c.NewValue)
+	}
+	return fmt.Sprintf("? %s", c.Path)
+}
+
+// nameString returns the name as written in the xml, like "tx:annotation-driven"
+func nameString(n Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// nodeString returns the xml of n
+func nodeString(n Node) string {
+	if n == nil {
+		return ""
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, 64))
+	n.Write(buf)
+	return buf.String()
+}
+
+type keyRule struct {
+	ele  string
+	attr Name
+}
+
+type differ struct {
+	opts    DiffOptions
+	keys    []keyRule
+	changes []Change
+}
+
+func newDiffer(opts *DiffOptions) *differ {
+	df := &differ{}
+	if opts != nil {
+		df.opts = *opts
+	}
+	df.keys = parseKeys(df.opts.Keys)
+	return df
+}
+
+// parseKeys turns rules like "bean@id" into keyRules, a rule without '@' is ignored
+func parseKeys(keys []string) []keyRule {
+	rt := make([]keyRule, 0, len(keys))
+	for _, k := range keys {
+		i := strings.LastIndex(k, "@")
+		if i < 0 {
+			continue
+		}
+		rt = append(rt, keyRule{ele: k[:i], attr: parseName(k[i+1:])})
+	}
+	return rt
+}
+
+// parseName splits "prefix:local" into a Name
+func parseName(s string) Name {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return NewName("", s)
+	}
+	return NewName(s[:i], s[i+1:])
+}
+
+// keyOf returns the key attr and its value of e, ok is false if no rule applies
+func keyOf(keys []keyRule, e *Ele) (Name, string, bool) {
+	ns := nameString(e.Name)
+	for _, k := range keys {
+		if k.ele != "*" && k.ele != ns && k.ele != e.Name.Local {
+			continue
+		}
+		v, ok := e.GetAttr(k.attr)
+		if ok {
+			return k.attr, v, true
+		}
+	}
+	return Name{}, "", false
+}
+
+// eleStep returns the path step of e and the key it is matched by,
+// count holds the occurrences of the element names seen so far
+func eleStep(keys []keyRule, e *Ele, count map[string]int) (string, string) {
+	ns := nameString(e.Name)
+	count["e:"+ns]++
+	attr, v, ok := keyOf(keys, e)
+	if ok {
+		step := ns + "[@" + nameString(attr) + "=" + xpathLiteral(v) + "]"
+		return step, "k:" + step
+	}
+	step := ns + "[" + strconv.Itoa(count["e:"+ns]) + "]"
+	return step, "e:" + step
+}
+
+// rootPath returns the path of e as the root element of a doc, the paths of
+// Diff, DiffEle and Merge3 all start with it
+func rootPath(keys []keyRule, e *Ele) string {
+	step, _ := eleStep(keys, e, make(map[string]int))
+	return "/" + step
+}
+
+func (df *differ) add(c Change) {
+	df.changes = append(df.changes, c)
+}
+
+// diffItem is a child taking part in the sibling matching
+type diffItem struct {
+	node  Node
+	key   string
+	step  string
+	index int
+}
+
+// items returns the children of p that are matched one by one, CharData is compared as text
+func (df *differ) items(p Iparent) []diffItem {
+	rt := make([]diffItem, 0, p.getNodes().Len())
+	count := make(map[string]int)
+	for _, x := range *p.getNodes() {
+		var key, step string
+		switch n := x.(type) {
+		case *Ele:
+			step, key = eleStep(df.keys, n, count)
+		case *Comment:
+			count["c"]++
+			step = "comment()[" + strconv.Itoa(count["c"]) + "]"
+			if df.opts.IgnoreComments {
+				continue
+			}
+			key = "c:" + n.V
+		case *ProcInst:
+			count["p"]++
+			step = "processing-instruction(" + xpathLiteral(n.Target) + ")[" + strconv.Itoa(count["p"]) + "]"
+			key = "p:" + n.Target + " " + n.Inst
+		case *Directive:
+			count["d"]++
+			step = "directive()[" + strconv.Itoa(count["d"]) + "]"
+			key = "d:" + n.V
+		case *DocType:
+			count["t"]++
+			step = "doctype()[" + strconv.Itoa(count["t"]) + "]"
+			key = "t:" + n.directive()
+		case *EntityRef:
+			count["r"]++
+			step = "entity()[" + strconv.Itoa(count["r"]) + "]"
+			key = "r:" + n.Name
+		default:
+			continue
+		}
+		count[key]++
+		key = key + "#" + strconv.Itoa(count[key])
+		rt = append(rt, diffItem{node: x, key: key, step: step, index: len(rt)})
+	}
+	return rt
+}
+
+func (df *differ) text(p Iparent) string {
+	if !df.opts.IgnoreWhitespace {
+		return text(p)
+	}
+	return trimedText(p)
+}
+
+func (df *differ) diffChildren(a, b Iparent, path string) {
+	ia := df.items(a)
+	ib := df.items(b)
+	byKey := make(map[string]int, len(ib))
+	for i, it := range ib {
+		byKey[it.key] = i
+	}
+	matched := make([]bool, len(ib))
+	// pairs[i] is the index in ib matching ia[i], or -1
+	pairs := make([]int, len(ia))
+	for i, it := range ia {
+		j, ok := byKey[it.key]
+		if ok {
+			pairs[i] = j
+			matched[j] = true
+		} else {
+			pairs[i] = -1
+		}
+	}
+	stay := longestIncreasing(pairs)
+	for i, it := range ia {
+		p := path + "/" + it.step
+		j := pairs[i]
+		if j < 0 {
+			df.add(Change{Type: ChangeDelete, Path: p, Old: it.node, OldIndex: it.index, NewIndex: -1})
+			continue
+		}
+		if !stay[i] {
+			df.add(Change{Type: ChangeMove, Path: p, Old: it.node, New: ib[j].node, OldIndex: it.index, NewIndex: j})
+		}
+		ea, ok := it.node.(*Ele)
+		if ok {
+			df.diffEle(ea, ib[j].node.(*Ele), p)
+		}
+	}
+	for j, it := range ib {
+		if !matched[j] {
+			df.add(Change{Type: ChangeInsert, Path: path + "/" + it.step, New: it.node, OldIndex: -1, NewIndex: j})
+		}
+	}
+}
+
+func (df *differ) diffEle(a, b *Ele, path string) {
+	df.diffAttrs(a, b, path)
+	ta := df.text(a)
+	tb := df.text(b)
+	if ta != tb {
+		df.add(Change{Type: ChangeText, Path: path, Old: a, New: b, OldValue: ta, NewValue: tb, OldIndex: -1, NewIndex: -1})
+	}
+	df.diffChildren(a, b, path)
+}
+
+func (df *differ) diffAttrs(a, b *Ele, path string) {
+	for _, attr := range a.attrs {
+		v, ok := b.GetAttr(attr.Name)
+		if !ok {
+			df.add(Change{Type: ChangeAttrRemove, Path: path, Old: a, New: b, Attr: attr.Name, OldValue: attr.Value, OldIndex: -1, NewIndex: -1})
+		} else if v != attr.Value {
+			df.add(Change{Type: ChangeAttrModify, Path: path, Old: a, New: b, Attr: attr.Name, OldValue: attr.Value, NewValue: v, OldIndex: -1, NewIndex: -1})
+		}
+	}
+	for _, attr := range b.attrs {
+		_, ok := a.GetAttr(attr.Name)
+		if !ok {
+			df.add(Change{Type: ChangeAttrAdd, Path: path, Old: a, New: b, Attr: attr.Name, NewValue: attr.Value, OldIndex: -1, NewIndex: -1})
+		}
+	}
+	if df.opts.IgnoreAttrOrder {
+		return
+	}
+	// compare the order of the attrs both elements have
+	ia, ib := 0, 0
+	for ia < len(a.attrs) && ib < len(b.attrs) {
+		na := a.attrs[ia].Name
+		nb := b.attrs[ib].Name
+		if _, ok := b.GetAttr(na); !ok {
+			ia++
+			continue
+		}
+		if _, ok := a.GetAttr(nb); !ok {
+			ib++
+			continue
+		}
+		if na != nb {
+			df.add(Change{Type: ChangeAttrOrder, Path: path, Old: a, New: b, OldIndex: -1, NewIndex: -1})
+			return
+		}
+		ia++
+		ib++
+	}
+}
+
+// longestIncreasing marks the entries of s (ignoring negative ones) forming the
+// longest strictly increasing subsequence, those are the nodes that did not move
+func longestIncreasing(s []int) []bool {
+	rt := make([]bool, len(s))
+	// tails[k] is the index in s of the smallest tail of an increasing run of length k+1
+	tails := make([]int, 0, len(s))
+	prev := make([]int, len(s))
+	for i, v := range s {
+		if v < 0 {
+			continue
+		}
+		lo, hi := 0, len(tails)
+		for lo < hi {
+			m := (lo + hi) / 2
+			if s[tails[m]] < v {
+				lo = m + 1
+			} else {
+				hi = m
+			}
+		}
+		if lo > 0 {
+			prev[i] = tails[lo-1]
+		} else {
+			prev[i] = -1
+		}
+		if lo == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[lo] = i
+		}
+	}
+	if len(tails) == 0 {
+		return rt
+	}
+	for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+		rt[i] = true
+	}
+	return rt
+}
+
+// xpathLiteral quotes s as an xpath string literal
+func xpathLiteral(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	if !strings.Contains(s, "\"") {
+		return "\"" + s + "\""
+	}
+	parts := strings.Split(s, "'")
+	return "concat('" + strings.Join(parts, "', \"'\", '") + "')"
+}
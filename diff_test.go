@@ -0,0 +1,65 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	xa := `<beans>
+    <!-- datasource -->
+    <bean id="a" class="A"><property name="url" value="1"/></bean>
+    <bean id="b" class="B"/>
+    <bean id="c" class="C">text</bean>
+</beans>`
+	xb := `<beans>
+    <bean id="c" class="C">new text</bean>
+    <bean id="a" class="A2"><property name="url" value="1"/></bean>
+    <bean id="d"/>
+</beans>`
+	a, _ := ParseString(xa)
+	b, _ := ParseString(xb)
+	cs := Diff(a, b, &DiffOptions{
+		Keys:             []string{"bean@id", "property@name"},
+		IgnoreWhitespace: true,
+		IgnoreComments:   true,
+	})
+	got := make(map[ChangeType][]string)
+	for _, c := range cs {
+		got[c.Type] = append(got[c.Type], c.Path)
+	}
+	if len(got[ChangeDelete]) != 1 || got[ChangeDelete][0] != "/beans[1]/bean[@id='b']" {
+		t.Error("wrong delete", got[ChangeDelete])
+	}
+	if len(got[ChangeInsert]) != 1 || got[ChangeInsert][0] != "/beans[1]/bean[@id='d']" {
+		t.Error("wrong insert", got[ChangeInsert])
+	}
+	if len(got[ChangeMove]) != 1 {
+		t.Error("wrong move", got[ChangeMove])
+	}
+	if len(got[ChangeAttrModify]) != 1 || got[ChangeAttrModify][0] != "/beans[1]/bean[@id='a']" {
+		t.Error("wrong attr modify", got[ChangeAttrModify])
+	}
+	if len(got[ChangeText]) != 1 {
+		t.Error("wrong text", got[ChangeText])
+	}
+	if t.Failed() {
+		t.Error(Report(cs))
+	}
+}
+
+func TestDiffOptions(t *testing.T) {
+	a, _ := ParseString(`<p a="1" b="2"><!--x--><c/></p>`)
+	b, _ := ParseString(`<p b="2" a="1">
+    <c/>
+</p>`)
+	cs := Diff(a, b, &DiffOptions{IgnoreWhitespace: true, IgnoreComments: true, IgnoreAttrOrder: true})
+	if len(cs) != 0 {
+		t.Error("should be equal")
+		t.Error(Report(cs))
+	}
+	cs = Diff(a, b, nil)
+	if len(cs) != 3 {
+		t.Error("expect attr order, text and comment changes")
+		t.Error(Report(cs))
+	}
+}
@@ -0,0 +1,258 @@
+package gdom
+
+import (
+	"io"
+	"strings"
+)
+
+// DocType is the <!DOCTYPE ...> of a doc. parse makes one of the DOCTYPE
+// directive in front of the root, it is written back as it was parsed until
+// one of its fields is changed
+type DocType struct {
+	idx            int
+	Name           string
+	PublicID       string
+	SystemID       string
+	InternalSubset string
+	parent         Iparent
+	pos            *Span
+	// the directive it was parsed from
+	raw string
+}
+
+func NewDocType(name, publicID, systemID string) *DocType {
+	return &DocType{
+		Name:     name,
+		PublicID: publicID,
+		SystemID: systemID,
+	}
+}
+
+// parseDocType makes the *DocType of the directive v, false if v is not a
+// DOCTYPE declaration
+func parseDocType(v string) (*DocType, bool) {
+	rest, ok := strings.CutPrefix(v, "DOCTYPE")
+	if !ok || rest == "" || !isTagSpace(rest[0]) {
+		return nil, false
+	}
+	dt := &DocType{raw: v}
+	rest = trimSpace(rest)
+	i := strings.IndexFunc(rest, func(r rune) bool {
+		return r == '[' || isTagSpace(byte(r))
+	})
+	if i < 0 {
+		i = len(rest)
+	}
+	dt.Name, rest = rest[:i], trimSpace(rest[i:])
+	if dt.Name == "" {
+		return nil, false
+	}
+	if r, ok := strings.CutPrefix(rest, "PUBLIC"); ok {
+		dt.PublicID, rest, ok = quoted(r)
+		if !ok {
+			return nil, false
+		}
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			dt.SystemID, rest, ok = quoted(rest)
+			if !ok {
+				return nil, false
+			}
+		}
+	} else if r, ok := strings.CutPrefix(rest, "SYSTEM"); ok {
+		dt.SystemID, rest, ok = quoted(r)
+		if !ok {
+			return nil, false
+		}
+	}
+	if strings.HasPrefix(rest, "[") {
+		j := strings.LastIndexByte(rest, ']')
+		if j < 0 {
+			return nil, false
+		}
+		dt.InternalSubset, rest = rest[1:j], trimSpace(rest[j+1:])
+	}
+	if rest != "" {
+		return nil, false
+	}
+	return dt, true
+}
+
+// quoted returns the quoted literal s starts with, after spaces, and the rest of s
+func quoted(s string) (string, string, bool) {
+	s = trimSpace(s)
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return "", s, false
+	}
+	j := strings.IndexByte(s[1:], s[0])
+	if j < 0 {
+		return "", s, false
+	}
+	return s[1 : j+1], trimSpace(s[j+2:]), true
+}
+
+func trimSpace(s string) string {
+	return strings.TrimLeft(s, " \t\r\n")
+}
+
+// directiveNode makes the node of the directive v at the top of a doc, a
+// *DocType for a DOCTYPE declaration
+func directiveNode(v string) Node {
+	if dt, ok := parseDocType(v); ok {
+		return dt
+	}
+	return NewDirective(v)
+}
+
+// directive returns the text of the directive dt, without <! and >
+func (dt *DocType) directive() string {
+	if dt.raw != "" {
+		p, ok := parseDocType(dt.raw)
+		if ok && p.Name == dt.Name && p.PublicID == dt.PublicID && p.SystemID == dt.SystemID && p.InternalSubset == dt.InternalSubset {
+			return dt.raw
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("DOCTYPE ")
+	sb.WriteString(dt.Name)
+	if dt.PublicID != "" {
+		sb.WriteString(" PUBLIC " + quote(dt.PublicID))
+		if dt.SystemID != "" {
+			sb.WriteString(" " + quote(dt.SystemID))
+		}
+	} else if dt.SystemID != "" {
+		sb.WriteString(" SYSTEM " + quote(dt.SystemID))
+	}
+	if dt.InternalSubset != "" {
+		sb.WriteString(" [" + dt.InternalSubset + "]")
+	}
+	return sb.String()
+}
+
+// quote quotes s with " if it can
+func quote(s string) string {
+	if strings.Contains(s, `"`) {
+		return "'" + s + "'"
+	}
+	return `"` + s + `"`
+}
+
+func (dt *DocType) Copy() Node {
+	cp := *dt
+	cp.idx = 0
+	cp.parent = nil
+	return &cp
+}
+
+func (dt *DocType) Write(w io.Writer) error {
+	_, err := io.WriteString(w, "<!"+dt.directive()+">")
+	return err
+}
+
+func (dt *DocType) index() int {
+	return dt.idx
+}
+
+func (dt *DocType) setIndex(i int) {
+	dt.idx = i
+}
+
+func (dt *DocType) GetParent() Iparent {
+	return dt.parent
+}
+
+func (dt *DocType) setParent(p Iparent) {
+	dt.parent = p
+}
+
+func (dt *DocType) clearParent() {
+	dt.parent = nil
+}
+
+func (dt *DocType) Pos() Span {
+	return spanOf(dt.pos)
+}
+
+func (dt *DocType) FirstChild() Node {
+	return nil
+}
+
+func (dt *DocType) LastChild() Node {
+	return nil
+}
+
+func (dt *DocType) NextSibling() Node {
+	return nextSibling(dt)
+}
+
+func (dt *DocType) PrevSibling() Node {
+	return prevSibling(dt)
+}
+
+func (dt *DocType) NextSiblingEle() *Ele {
+	return nextSiblingEle(dt)
+}
+
+func (dt *DocType) PrevSiblingEle() *Ele {
+	return prevSiblingEle(dt)
+}
+
+func (dt *DocType) ParentEle() *Ele {
+	return parentEle(dt)
+}
+
+func (dt *DocType) OwnerDoc() *Doc {
+	return ownerDoc(dt)
+}
+
+func (dt *DocType) Ancestors() []*Ele {
+	return ancestors(dt)
+}
+
+func (dt *DocType) Index() int {
+	return index(dt)
+}
+
+func addDocType(p Iparent, dt *DocType) {
+	mustMutable("AddDocType", p)
+	pushNode(p, dt)
+}
+
+// return the *DocType of d, nil if it has none
+func (d *Doc) DocType() *DocType {
+	for _, n := range d.nodes {
+		if dt, ok := n.(*DocType); ok {
+			return dt
+		}
+	}
+	return nil
+}
+
+// SetDocType puts dt in the place of the DOCTYPE of d, or in front of the root if
+// d has none. dt is taken out of where it was, a nil dt removes the DOCTYPE
+func (d *Doc) SetDocType(dt *DocType) error {
+	if err := frozenErr("SetDocType", dt, d); err != nil {
+		return err
+	}
+	old := d.DocType()
+	if dt == old {
+		return nil
+	}
+	if dt != nil {
+		detach(dt)
+	}
+	i := -1
+	if old != nil {
+		i = old.index()
+		removeAt(d, i)
+	} else if d.root != nil {
+		i = d.root.index()
+	}
+	if dt == nil {
+		return nil
+	}
+	if i < 0 {
+		i = d.nodes.Len()
+	}
+	insertNode(d, i, dt)
+	return nil
+}
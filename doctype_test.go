@@ -0,0 +1,52 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestDocType(t *testing.T) {
+	src := `<?xml version="1.0"?>
+<!DOCTYPE mapper PUBLIC "-//mybatis.org//DTD Mapper 3.0//EN"
+  "http://mybatis.org/dtd/mybatis-3-mapper.dtd">
+<mapper/>`
+	d, err := ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dt := d.DocType()
+	if dt == nil || dt.Name != "mapper" || dt.PublicID != "-//mybatis.org//DTD Mapper 3.0//EN" || dt.SystemID != "http://mybatis.org/dtd/mybatis-3-mapper.dtd" {
+		t.Fatal("doctype:", dt)
+	}
+	if len(d.AllDirectives()) != 0 {
+		t.Error("doctype is also a directive")
+	}
+	if d.ToString() != src {
+		t.Error("round trip:", d.ToString())
+	}
+	dt.SystemID = "mapper.dtd"
+	if nodeString(dt) != `<!DOCTYPE mapper PUBLIC "-//mybatis.org//DTD Mapper 3.0//EN" "mapper.dtd">` {
+		t.Error("changed:", nodeString(dt))
+	}
+	sub, ok := parseDocType(`DOCTYPE note SYSTEM 'note.dtd' [<!ENTITY a "b">]`)
+	if !ok || sub.SystemID != "note.dtd" || sub.InternalSubset != `<!ENTITY a "b">` {
+		t.Error("internal subset:", sub)
+	}
+	lazy, _ := ParseLazy([]byte(src))
+	if lazy.DocType() == nil || lazy.Persistent().Doc().DocType() == nil {
+		t.Error("lazy or persistent doc lost the doctype")
+	}
+
+	d, _ = ParseString(`<?xml version="1.0"?><html/>`)
+	d.SetDocType(NewDocType("html", "", ""))
+	if d.ToString() != `<?xml version="1.0"?><!DOCTYPE html><html/>` {
+		t.Error("set:", d.ToString())
+	}
+	d.SetDocType(NewDocType("html", "-//W3C//DTD XHTML 1.0 Strict//EN", "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd"))
+	if d.DocType().PublicID == "" || d.Root().Index() != 2 {
+		t.Error("replace:", d.ToString())
+	}
+	d.SetDocType(nil)
+	if d.DocType() != nil || d.ToString() != `<?xml version="1.0"?><html/>` {
+		t.Error("remove:", d.ToString())
+	}
+}
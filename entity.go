@@ -0,0 +1,387 @@
+package gdom
+
+//go:generate go run ./internal/gen/entities -o entity_html.go
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"strings"
+)
+
+// maxEntityLen is the longest text an entity of an internal DTD subset may
+// stand for, entities made of entities grow fast
+const maxEntityLen = 1 << 16
+
+var (
+	ErrEntityTooLong = errors.New("entity expands to more than 64KB")
+	ErrEntityLoop    = errors.New("entity refers to itself")
+)
+
+// EntityRef is a reference to a named entity in the text of an element, kept by
+// ParseOptions.KeepEntityRefs. it is written as &Name;
+type EntityRef struct {
+	idx  int
+	Name string
+	// the text the entity stood for when it was parsed
+	Value  string
+	parent Iparent
+	pos    *Span
+}
+
+func NewEntityRef(name, value string) *EntityRef {
+	return &EntityRef{
+		Name:  name,
+		Value: value,
+	}
+}
+
+func (r *EntityRef) Copy() Node {
+	cp := NewEntityRef(r.Name, r.Value)
+	cp.pos = r.pos
+	return cp
+}
+
+func (r *EntityRef) Write(w io.Writer) error {
+	_, err := io.WriteString(w, "&"+r.Name+";")
+	return err
+}
+
+func (r *EntityRef) index() int {
+	return r.idx
+}
+
+func (r *EntityRef) setIndex(i int) {
+	r.idx = i
+}
+
+func (r *EntityRef) GetParent() Iparent {
+	return r.parent
+}
+
+func (r *EntityRef) setParent(p Iparent) {
+	r.parent = p
+}
+
+func (r *EntityRef) clearParent() {
+	r.parent = nil
+}
+
+func (r *EntityRef) Pos() Span {
+	return spanOf(r.pos)
+}
+
+func (r *EntityRef) FirstChild() Node {
+	return nil
+}
+
+func (r *EntityRef) LastChild() Node {
+	return nil
+}
+
+func (r *EntityRef) NextSibling() Node {
+	return nextSibling(r)
+}
+
+func (r *EntityRef) PrevSibling() Node {
+	return prevSibling(r)
+}
+
+func (r *EntityRef) NextSiblingEle() *Ele {
+	return nextSiblingEle(r)
+}
+
+func (r *EntityRef) PrevSiblingEle() *Ele {
+	return prevSiblingEle(r)
+}
+
+func (r *EntityRef) ParentEle() *Ele {
+	return parentEle(r)
+}
+
+func (r *EntityRef) OwnerDoc() *Doc {
+	return ownerDoc(r)
+}
+
+func (r *EntityRef) Ancestors() []*Ele {
+	return ancestors(r)
+}
+
+func (r *EntityRef) Index() int {
+	return index(r)
+}
+
+func (e *Ele) AddEntityRef(r *EntityRef) {
+	addEntityRef(e, r.Copy().(*EntityRef))
+}
+
+func addEntityRef(e Iparent, r *EntityRef) {
+	mustMutable("AddEntityRef", e)
+	pushNode(e, r)
+}
+
+// entityTable returns the named entities of opts for the decoder, nil if there are none
+func (opts *ParseOptions) entityTable() map[string]string {
+	if !opts.HTMLEntities {
+		return opts.Entities
+	}
+	if len(opts.Entities) == 0 {
+		return html5Entities()
+	}
+	table := maps.Clone(html5Entities())
+	maps.Copy(table, opts.Entities)
+	return table
+}
+
+// withDTD returns table with the entities declared in the internal subset of
+// dt, table itself is not changed
+func withDTD(table map[string]string, dt *DocType) (map[string]string, error) {
+	if dt.InternalSubset == "" {
+		return table, nil
+	}
+	ents, err := dt.entities(table)
+	if err != nil || len(ents) == 0 {
+		return table, err
+	}
+	nt := make(map[string]string, len(table)+len(ents))
+	maps.Copy(nt, table)
+	maps.Copy(nt, ents)
+	return nt, nil
+}
+
+// Entities returns the internal general entities declared in the internal subset
+// of dt, with the text a reference to them stands for in the content. a
+// reference to an entity that is not declared is left as it is
+func (dt *DocType) Entities() (map[string]string, error) {
+	return dt.entities(nil)
+}
+
+// entities is Entities, the references to the entities of table are replaced too
+func (dt *DocType) entities(table map[string]string) (map[string]string, error) {
+	// the replacement texts, XML 1.0 4.5: only the character references are
+	// replaced where an entity is declared
+	repl := make(map[string]string)
+	s := dt.InternalSubset
+	for {
+		i := strings.Index(s, "<!")
+		if i < 0 {
+			break
+		}
+		s = s[i:]
+		if strings.HasPrefix(s, "<!--") {
+			j := strings.Index(s, "-->")
+			if j < 0 {
+				break
+			}
+			s = s[j+3:]
+			continue
+		}
+		rest, ok := strings.CutPrefix(s, "<!ENTITY")
+		s = s[2:]
+		if !ok || rest == "" || !isTagSpace(rest[0]) {
+			continue
+		}
+		rest = trimSpace(rest)
+		// parameter entities are for the DTD, not for the text
+		if strings.HasPrefix(rest, "%") {
+			continue
+		}
+		j := strings.IndexFunc(rest, func(r rune) bool {
+			return isTagSpace(byte(r))
+		})
+		if j <= 0 {
+			continue
+		}
+		name := rest[:j]
+		// an external entity has no literal value
+		v, _, ok := quoted(rest[j:])
+		if !ok {
+			continue
+		}
+		if _, ok := repl[name]; !ok {
+			// the first declaration is binding
+			repl[name] = replaceRefs(v, func(name string) (string, bool) {
+				return entityOf(name), name[0] == '#'
+			})
+		}
+	}
+	x := &expander{repl: repl, table: table, ents: make(map[string]string, len(repl)), using: make(map[string]bool)}
+	for name := range repl {
+		if _, err := x.use(name); err != nil {
+			return nil, err
+		}
+	}
+	return x.ents, nil
+}
+
+// expander makes the text the references to the entities of a DTD stand for
+type expander struct {
+	repl  map[string]string
+	table map[string]string
+	// the texts made so far
+	ents map[string]string
+	// the entities being made, to find the loops
+	using map[string]bool
+}
+
+// use returns the text a reference to the entity name of the DTD stands for: its
+// replacement text with the references in it replaced, once
+func (x *expander) use(name string) (string, error) {
+	if v, ok := x.ents[name]; ok {
+		return v, nil
+	}
+	if x.using[name] {
+		return "", ErrEntityLoop
+	}
+	x.using[name] = true
+	var err error
+	v := replaceRefs(x.repl[name], func(ref string) (string, bool) {
+		if err != nil {
+			return "", false
+		}
+		if _, ok := x.repl[ref]; ok && isEntityRef(ref) {
+			var v string
+			v, err = x.use(ref)
+			return v, err == nil
+		}
+		if v, ok := x.table[ref]; ok && isEntityRef(ref) {
+			return v, true
+		}
+		v := entityOf(ref)
+		return v, v != "&"+ref+";"
+	})
+	delete(x.using, name)
+	if err == nil && len(v) > maxEntityLen {
+		err = ErrEntityTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+	x.ents[name] = v
+	return v, nil
+}
+
+// replaceRefs replaces the references &name; in v by what f returns for name,
+// those f returns false for are left as they are
+func replaceRefs(v string, f func(name string) (string, bool)) string {
+	if !strings.Contains(v, "&") {
+		return v
+	}
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(v, '&')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(v[i:], ';')
+		if j < 0 {
+			break
+		}
+		sb.WriteString(v[:i])
+		name := v[i+1 : i+j]
+		r, ok := "", false
+		if name != "" {
+			r, ok = f(name)
+		}
+		if ok {
+			sb.WriteString(r)
+		} else {
+			sb.WriteString(v[i : i+j+1])
+		}
+		v = v[i+j+1:]
+		if sb.Len() > maxEntityLen {
+			// the caller finds the text too long
+			return sb.String()
+		}
+	}
+	sb.WriteString(v)
+	return sb.String()
+}
+
+// isEntityRef reports whether &name; refers to a named entity that is not one
+// of the five of xml
+func isEntityRef(name string) bool {
+	switch name {
+	case "lt", "gt", "amp", "apos", "quot":
+		return false
+	}
+	return name != "" && name[0] != '#'
+}
+
+// hasRefs reports whether the text whose source is b refers to a named entity of
+// table, a CDATA section never does
+func hasRefs(b []byte, table map[string]string) bool {
+	if len(table) == 0 || isCDATA(b) {
+		return false
+	}
+	for i := bytes.IndexByte(b, '&'); i >= 0; i = bytes.IndexByte(b, '&') {
+		b = b[i+1:]
+		k := bytes.IndexByte(b, ';')
+		if k < 0 {
+			return false
+		}
+		if _, ok := table[string(b[:k])]; ok && isEntityRef(string(b[:k])) {
+			return true
+		}
+	}
+	return false
+}
+
+// addRefs adds the text whose source is b to p, the references to the named
+// entities of table become *EntityRef nodes. the nodes get their spans from slab
+// if it is not nil, b starts at start. lossless keeps the source of the text
+func addRefs(p Iparent, b []byte, start Position, table map[string]string, slab *spanSlab, lossless bool) {
+	add := func(n Node, from, to int) {
+		var sp *Span
+		if slab != nil {
+			sp = slab.new(Span{Start: advance(start, b[:from])})
+			sp.End = advance(sp.Start, b[from:to])
+		}
+		switch t := n.(type) {
+		case *CharData:
+			t.pos = sp
+			if lossless {
+				t.raw = &rawText{b: string(b[from:to]), v: t.V}
+			}
+			addCharData(p, t)
+			if last := p.getNodes().Back().(*CharData); last != t {
+				// merged into the CharData before it
+				if last.pos != nil && sp != nil {
+					last.pos.End = sp.End
+				}
+				last.extend(b[from:to])
+			}
+		case *EntityRef:
+			t.pos = sp
+			addEntityRef(p, t)
+		}
+	}
+	last := 0
+	for i := 0; i < len(b); {
+		j := bytes.IndexByte(b[i:], '&')
+		if j < 0 {
+			break
+		}
+		i += j
+		k := bytes.IndexByte(b[i:], ';')
+		if k < 0 {
+			break
+		}
+		name := string(b[i+1 : i+k])
+		v, ok := table[name]
+		if !ok || !isEntityRef(name) {
+			i += k + 1
+			continue
+		}
+		if last < i {
+			add(NewCharData(textOf(b[last:i])), last, i)
+		}
+		add(NewEntityRef(name, v), i, i+k+1)
+		i += k + 1
+		last = i
+	}
+	if last < len(b) {
+		add(NewCharData(textOf(b[last:])), last, len(b))
+	}
+}
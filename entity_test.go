@@ -0,0 +1,125 @@
+package gdom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEntities(t *testing.T) {
+	src := `<!DOCTYPE p [
+  <!ENTITY % pe "skipped">
+  <!ENTITY product "gdom">
+  <!ENTITY full "&product; &#169; &co;">
+  <!ENTITY logo SYSTEM "logo.png">
+]><p a="&product;">&full; &nbsp;&lt;</p>`
+	d, err := ParseWithOptions(strings.NewReader(src), &ParseOptions{
+		Entities:     map[string]string{"co": "Acme"},
+		HTMLEntities: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Root().Text() != "gdom © Acme  <" {
+		t.Error("text:", d.Root().Text())
+	}
+	if v, _ := d.Root().GetAttr(NewName("", "a")); v != "gdom" {
+		t.Error("attr:", v)
+	}
+	ents, _ := d.DocType().Entities()
+	if len(ents) != 2 || ents["full"] != "gdom © &co;" {
+		t.Error("dtd entities:", ents)
+	}
+
+	dt := NewDocType("p", "", "")
+	dt.InternalSubset = `<!ENTITY p "Prod &amp;amp; Co"><!ENTITY q "&#38;#38; &p;"><!ENTITY e "&;">`
+	ents, err = dt.Entities()
+	if err != nil || ents["p"] != "Prod &amp; Co" || ents["q"] != "& Prod &amp; Co" || ents["e"] != "&;" {
+		t.Error("replacement text:", ents, err)
+	}
+	dt.InternalSubset = `<!ENTITY a "x&b;"><!ENTITY b "&a;">`
+	if _, err := dt.Entities(); !errors.Is(err, ErrEntityLoop) {
+		t.Error("entity loop:", err)
+	}
+
+	bomb := `<!DOCTYPE b [<!ENTITY a "aaaaaaaaaaaaaaaa">` +
+		`<!ENTITY b "&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;&a;">` +
+		`<!ENTITY c "&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;&b;">` +
+		`<!ENTITY d "&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;&c;">` +
+		`<!ENTITY e "&d;&d;&d;&d;&d;&d;&d;&d;&d;&d;&d;&d;&d;&d;&d;&d;">]><b>&e;</b>`
+	if _, err := ParseString(bomb); !errors.Is(err, ErrEntityTooLong) {
+		t.Error("entity bomb:", err)
+	}
+}
+
+func TestKeepEntityRefs(t *testing.T) {
+	src := `<!DOCTYPE p [<!ENTITY product "gdom">]>
+<p>use &product; &amp; &copy;<![CDATA[&product;]]></p>`
+	opts := &ParseOptions{HTMLEntities: true, KeepEntityRefs: true, Positions: true}
+	d, err := ParseWithOptions(strings.NewReader(src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := d.Root().AllNodes()
+	if len(nodes) != 5 {
+		t.Fatal("nodes:", len(nodes))
+	}
+	ref, ok := nodes[1].(*EntityRef)
+	if !ok || ref.Name != "product" || ref.Value != "gdom" {
+		t.Error("ref:", nodes[1])
+	}
+	if cd := nodes[2].(*CharData); cd.V != " & " {
+		t.Error("text between:", cd.V)
+	}
+	if ref.Pos().Start.Line != 2 || ref.Pos().Start.Column != 8 || ref.Pos().End.Column != 17 {
+		t.Error("pos:", ref.Pos())
+	}
+	if d.Root().Text() != "use gdom & ©&product;" {
+		t.Error("text:", d.Root().Text())
+	}
+	if d.ToString() != src {
+		t.Error("round trip:", d.ToString())
+	}
+	if d.Persistent().Doc().ToString() != src {
+		t.Error("persistent:", d.Persistent().Doc().ToString())
+	}
+	lazy, err := ParseLazyWithOptions([]byte(src), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lazy.Root().AllNodes()[1].(*EntityRef); !ok || lazy.ToString() != src {
+		t.Error("lazy:", lazy.ToString())
+	}
+}
+
+func TestEntityRefText(t *testing.T) {
+	parse := func(s, p string) *Doc {
+		d, err := ParseWithOptions(strings.NewReader(`<!DOCTYPE a [<!ENTITY p "`+p+`">]>`+s), &ParseOptions{KeepEntityRefs: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	a, b := parse(`<a>x &p;</a>`, "Prod"), parse(`<a>x &p;</a>`, "Other")
+	if a.Root().TrimedText() != "xProd" {
+		t.Error("trimed text:", a.Root().TrimedText())
+	}
+	if len(Diff(a, b, &DiffOptions{IgnoreWhitespace: true})) == 0 {
+		t.Error("changed entity value not found")
+	}
+	m, cs := Merge3(a, a, parse(`<a>y &p;</a>`, "Prod"), nil)
+	if len(cs) != 0 || m.Root().ToString() != `<a>y &p;</a>` {
+		t.Error("merge:", cs, m.Root().ToString())
+	}
+}
+
+func TestEntityRefLossless(t *testing.T) {
+	src := `<!DOCTYPE a [<!ENTITY p "Prod">]><a>&#65;&p;&#x42;&lt;</a>`
+	d, err := ParseWithOptions(strings.NewReader(src), &ParseOptions{KeepEntityRefs: true, Lossless: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ToString() != src || d.Root().Text() != "AProdB<" {
+		t.Error("lossless round trip:", d.ToString())
+	}
+}
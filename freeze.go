@@ -0,0 +1,70 @@
+package gdom
+
+// Freeze returns an immutable copy of d, d itself stays mutable. the lazy
+// content of ParseLazy is materialized in the copy, so that reads no longer
+// change anything: a frozen doc can be read from many goroutines at once through
+// Root, IterNode, Eles, AllEles, AllNodes, GetAttr, Text, the navigation
+// methods, the iterators, Write, ToString, Copy, Diff and Check.
+// the mutators that return an error return an ErrFrozen TreeError on a frozen
+// doc, the others panic with one. the exported fields, like Name, Value and V,
+// must not be assigned. Copy gives a mutable copy, Freeze of a frozen doc
+// returns it as it is
+func (d *Doc) Freeze() *Doc {
+	if d.frozen {
+		return d
+	}
+	cp := d.Copy()
+	for _, n := range cp.nodes {
+		if e, ok := n.(*Ele); ok {
+			e.freeze()
+		}
+	}
+	cp.frozen = true
+	return cp
+}
+
+func (e *Ele) freeze() {
+	e.load()
+	for _, n := range e.nodes {
+		if c, ok := n.(*Ele); ok {
+			c.freeze()
+		}
+	}
+	e.frozen = true
+}
+
+// IsFrozen reports whether Freeze was called on d
+func (d *Doc) IsFrozen() bool {
+	return d.frozen
+}
+
+// IsFrozen reports whether e is in a frozen doc
+func (e *Ele) IsFrozen() bool {
+	return e.frozen
+}
+
+func (d *Doc) isFrozen() bool {
+	return d.frozen
+}
+
+func (e *Ele) isFrozen() bool {
+	return e.frozen
+}
+
+// frozenErr returns an ErrFrozen TreeError if one of ps is frozen
+func frozenErr(op string, n Node, ps ...Iparent) error {
+	for _, p := range ps {
+		if p != nil && p.isFrozen() {
+			return treeErr(op, n, ErrFrozen)
+		}
+	}
+	return nil
+}
+
+// mustMutable panics for the mutators that can't return an error
+func mustMutable(op string, p Iparent) {
+	err := frozenErr(op, nil, p)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,85 @@
+package gdom
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	src, _ := ParseLazy([]byte(`<beans><bean id="a"><property name="x"/></bean><bean id="b"/></beans>`))
+	d := src.Freeze()
+	r := d.Root()
+	if !d.IsFrozen() || !r.IsFrozen() || r.lazy != nil || d.Freeze() != d {
+		t.Error("doc not frozen")
+	}
+	// the doc Freeze is called on is not frozen
+	if src.IsFrozen() || src.Root().IsFrozen() {
+		t.Error("source frozen")
+	}
+	src.Root().AllEles()[0].SetAttr(NewAttr(NewName("", "id"), "s"))
+	if v, _ := r.AllEles()[0].GetAttrByStrName("", "id"); v != "a" {
+		t.Error("frozen copy changed with its source")
+	}
+	a := r.AllEles()[0]
+	if err := r.RemoveNode(a); !errors.Is(err, ErrFrozen) {
+		t.Error("remove not rejected", err)
+	}
+	if err := MoveAfter(a, r.AllEles()[1]); !errors.Is(err, ErrFrozen) {
+		t.Error("move not rejected", err)
+	}
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, ErrFrozen) {
+				t.Error("set attr did not panic", err)
+			}
+		}()
+		a.SetAttr(NewAttr(NewName("", "id"), "c"))
+	}()
+	cp := d.Copy()
+	cp.Root().AllEles()[0].SetAttr(NewAttr(NewName("", "id"), "c"))
+	if cp.IsFrozen() || cp.Root().AllEles()[0].IsFrozen() {
+		t.Error("copy is frozen")
+	}
+	if v, _ := a.GetAttrByStrName("", "id"); v != "a" {
+		t.Error("frozen doc changed")
+	}
+}
+
+// the reads below are meant to be checked with go test -race
+func TestFrozenConcurrentReads(t *testing.T) {
+	var buf strings.Builder
+	buf.WriteString("<beans>")
+	for i := 0; i < 100; i++ {
+		buf.WriteString(`<bean id="b"><property name="p" value="v"/>text</bean>`)
+	}
+	buf.WriteString("</beans>")
+	d, _ := ParseLazy([]byte(buf.String()))
+	d = d.Freeze()
+	want := d.ToString()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cnt := 0
+			for _, b := range d.Root().ElesByStrName("", "bean") {
+				if v, ok := b.GetAttrByStrName("", "id"); ok && v == "b" && b.Text() == "text" {
+					cnt++
+				}
+				cnt += len(b.AllEles())
+			}
+			for n := range d.All() {
+				if n.Index() < 0 || n.OwnerDoc() != d {
+					t.Error("wrong node")
+				}
+			}
+			if cnt != 200 || d.ToString() != want || d.Check() != nil {
+				t.Error("wrong read")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -2,7 +2,6 @@ package gdom
 
 import (
 	"bytes"
-	"container/list"
 	"encoding/xml"
 	"errors"
 	"io"
@@ -24,72 +23,183 @@ func NewName(space, local string) Name {
 // Write Name to w
 func (n *Name) Write(w io.Writer) error {
 	if n.Space != "" {
-		_, err := w.Write([]byte(n.Space))
+		_, err := io.WriteString(w, n.Space)
 		if err != nil {
 			return err
 		}
-		_, err = w.Write([]byte(":"))
+		_, err = io.WriteString(w, ":")
 		if err != nil {
 			return err
 		}
 	}
-	_, err := w.Write([]byte(n.Local))
+	_, err := io.WriteString(w, n.Local)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// Node holding one of the types: *Ele, *Comment, *ProcInst, *CharData, *Directive
-// make sure that node.Value == node
+// Node holding one of the types: *Ele, *Comment, *ProcInst, *CharData, *Directive, *DocType, *EntityRef
 type Node interface {
 	// make a Copy of the node
 	Copy() Node
 	// Write the node into w
 	Write(w io.Writer) error
 
-	pos() *list.Element
+	index() int
 
-	clearpos()
-
-	syncElement(element *list.Element)
+	setIndex(i int)
 
 	GetParent() Iparent
 
+	// the sibling after/before the node, nil if there is none
+	NextSibling() Node
+	PrevSibling() Node
+	// the next/previous sibling that is an *Ele, nil if there is none
+	NextSiblingEle() *Ele
+	PrevSiblingEle() *Ele
+	// where the node is in the source, the zero Span for nodes not made by Parse
+	Pos() Span
+	// the first/last child node, always nil for nodes other than *Ele
+	FirstChild() Node
+	LastChild() Node
+	// the parent if it is an *Ele, nil for the root and detached nodes
+	ParentEle() *Ele
+	// the Doc the node is in, nil for detached nodes
+	OwnerDoc() *Doc
+	// the *Ele ancestors from the parent up to the root
+	Ancestors() []*Ele
+	// the position among the children of the parent, -1 for detached nodes
+	Index() int
+
+	setParent(p Iparent)
+
 	clearParent()
 }
 
 func RemoveSelf(n Node) {
 	p := n.GetParent()
 	if p != nil {
-		p.getNodes().Remove(n.pos())
+		removeNode(p, n)
 	}
 }
 
 // only *Ele, *Doc
 type Iparent interface {
-	// the struct implement Iparent should return the list holding the nodes
-	getNodes() *list.List
-	// call list.New()
+	// the struct implement Iparent should return the nodeList holding the nodes
+	getNodes() *nodeList
+	// drop all the nodes
 	renewNodes()
+	// whether Freeze was called on the doc
+	isFrozen() bool
+}
+
+// nodeList holds the child nodes in order, each node knows its index in it
+type nodeList []Node
+
+func (l nodeList) Len() int {
+	return len(l)
+}
+
+// return the node at i, nil if i is out of range
+func (l nodeList) at(i int) Node {
+	if i < 0 || i >= len(l) {
+		return nil
+	}
+	return l[i]
+}
+
+func (l nodeList) Front() Node {
+	return l.at(0)
+}
+
+func (l nodeList) Back() Node {
+	return l.at(len(l) - 1)
+}
+
+func (l *nodeList) PushBack(n Node) {
+	n.setIndex(len(*l))
+	*l = append(*l, n)
+}
+
+// insert n at i, the nodes from i on move one place back
+func (l *nodeList) Insert(i int, n Node) {
+	*l = append(*l, nil)
+	copy((*l)[i+1:], (*l)[i:])
+	(*l)[i] = n
+	l.reindex(i)
+}
+
+// remove the node at i, the nodes after it move one place forward
+func (l *nodeList) Remove(i int) {
+	copy((*l)[i:], (*l)[i+1:])
+	(*l)[len(*l)-1] = nil
+	*l = (*l)[:len(*l)-1]
+	l.reindex(i)
+}
+
+// removeIf removes the nodes f returns true for and clears their parent
+func (l *nodeList) removeIf(f func(n Node) bool) {
+	j := 0
+	for _, n := range *l {
+		if f(n) {
+			n.setIndex(-1)
+			n.clearParent()
+			continue
+		}
+		(*l)[j] = n
+		j++
+	}
+	clear((*l)[j:])
+	*l = (*l)[:j]
+	l.reindex(0)
+}
+
+func (l nodeList) reindex(from int) {
+	for i := from; i < len(l); i++ {
+		l[i].setIndex(i)
+	}
+}
+
+// attached reports whether n is held by the nodeList of its parent
+func attached(n Node) bool {
+	p := n.GetParent()
+	return p != nil && p.getNodes().at(n.index()) == n
 }
 
 // Node Iteration Function def, return false to abort iteration
 type IterNodeFunc func(node Node) bool
 
+// f may remove the current node, the iteration stops if the next node is removed
 func iterNode(p Iparent, f IterNodeFunc) {
-	rt := true
-	for x := p.getNodes().Front(); rt && x != nil; {
-		nxt := x.Next()
-		rt = f(x.Value.(Node))
-		x = nxt
+	l := p.getNodes()
+	for i := 0; i < l.Len(); {
+		nxt := l.at(i + 1)
+		if !f((*l)[i]) {
+			return
+		}
+		if nxt == nil || nxt.GetParent() != p {
+			return
+		}
+		i = nxt.index()
 	}
 }
 
 // Doc xml document type
 type Doc struct {
-	nodes *list.List
-	root  *Ele
+	nodes  nodeList
+	root   *Ele
+	frozen bool
+	// the open transaction and the committed ones, see Begin
+	tx   *Tx
+	undo [][]change
+	redo [][]change
+	// Undo, Redo or Rollback is making its changes
+	replaying bool
+	// see Observe
+	observers []*observer
+	// see FileName
+	file string
 }
 
 func (d *Doc) IterNode(f IterNodeFunc) {
@@ -97,88 +207,115 @@ func (d *Doc) IterNode(f IterNodeFunc) {
 }
 
 func NewDoc(rootName Name) *Doc {
-	nodes := list.New()
 	root := NewEle(rootName, nil)
-	nodes.PushBack(root)
-	return &Doc{
-		nodes: nodes,
-		root:  root,
+	d := &Doc{
+		root: root,
 	}
+	addEle(d, root)
+	return d
 }
 
-func (d *Doc) getNodes() *list.List {
-	return d.nodes
+func (d *Doc) getNodes() *nodeList {
+	return &d.nodes
 }
 
 func (d *Doc) renewNodes() {
-	d.nodes = list.New()
+	d.nodes = nil
 }
 
 func (d *Doc) Beautiful() {
+	mustMutable("Beautiful", d)
 	d.Root().Beautiful()
 }
 
-// n is the node to insert, pos is the *list.Element holding a node which is in the Doc
+// n is the node to insert, pos is a node which is in the Doc
 // n can't be a *Ele
 func (e *Doc) InsertBefore(n Node, pos Node) error {
 
 	_, ok := n.(*Ele)
 	if ok {
-		return errors.New("can't insert ele")
+		return treeErr("InsertBefore", n, ErrRoot)
 	}
 	return insertBefore(e, n, pos)
 }
 
-// n is the node to insert, pos is the *list.Element holding a node which is in the Doc
+// n is the node to insert, pos is a node which is in the Doc
 func (e *Doc) InsertAfter(n Node, pos Node) error {
 	_, ok := n.(*Ele)
 	if ok {
-		return errors.New("can't insert ele")
+		return treeErr("InsertAfter", n, ErrRoot)
 	}
 	return insertAfter(e, n, pos)
 }
 
+// make a deep copy of the doc
+func (d *Doc) Copy() *Doc {
+	cp := &Doc{
+		nodes: make(nodeList, 0, len(d.nodes)),
+		root:  nil,
+		file:  d.file,
+	}
+	for _, x := range d.nodes {
+		n := x.Copy()
+		n.setParent(cp)
+		cp.nodes.PushBack(n)
+		if x == Node(d.root) {
+			cp.root = n.(*Ele)
+		}
+	}
+	return cp
+}
+
 // return the root *Ele of the xml doc
 func (d *Doc) Root() *Ele {
 	return d.root
 }
 
+// e takes the place of the root *Ele, it is detached from its parent first
 func (d *Doc) SetRoot(e *Ele) {
-	d.root = e
-	for x := d.nodes.Front(); x != nil; {
-		nxt := x.Next()
-		_, ok := x.Value.(*Ele)
+	mustMutable("SetRoot", d)
+	mustMutable("SetRoot", e.parent)
+	detach(e)
+	placed := false
+	for _, x := range allNodes(d) {
+		ele, ok := x.(*Ele)
 		if ok {
-			d.nodes.Remove(x)
+			if !placed {
+				attach(d, e, x)
+				placed = true
+			}
+			removeNode(d, ele)
 		}
-		x = nxt
 	}
+	if !placed {
+		attach(d, e, nil)
+	}
+	setRoot(d, e)
 }
 
-// return a slice of *list.Element, each of it is holding a *Comment
+// return the *Comment nodes of the doc
 func (e *Doc) AllComments() []*Comment {
 	return allComments(e)
 }
 
-// return a slice of *list.Element, each of it is holding a *Directive
+// return the *Directive nodes of the doc
 func (e *Doc) AllDirectives() []*Directive {
 	return allDirectives(e)
 }
 
-// return a slice of *list.Element, each of it is holding a *ProcInst
+// return the *ProcInst nodes of the doc
 func (e *Doc) AllProcInsts() []*ProcInst {
 	return allProcInsts(e)
 }
 
-// return a slice of *list.Element, each of it is holding a *CharData
+// return the *CharData nodes of the doc
 func (e *Doc) AllCharData() []*CharData {
 	return allCharData(e)
 }
 
 // Write the xml doc into w
 func (d *Doc) Write(w io.Writer) error {
-	for x := d.nodes.Front(); x != nil; x = x.Next() {
-		v := x.Value.(Node)
+	for _, v := range d.nodes {
 		err := v.Write(w)
 		if err != nil {
 			return err
@@ -200,8 +337,45 @@ func (d *Doc) ToBytes() []byte {
 }
 
 func Parse(r io.Reader) (d *Doc, err error) {
-	decoder := xml.NewDecoder(r)
-	return parse(decoder)
+	return ParseWithOptions(r, nil)
+}
+
+// ParseOptions controls ParseWithOptions, nil means the zero value
+type ParseOptions struct {
+	// the table the names and the whitespace of the doc are interned in, share
+	// one between the parses of many similar docs. nil uses a table per parse
+	Names *NameTable
+	// the name of the file the doc is read from, see Doc.Location
+	FileName string
+	// keep where each node and attr is in the source, see Node.Pos. WriteMinimal
+	// needs them
+	Positions bool
+	// keep the source of the tags, the text, with its entity references and CDATA
+	// sections, and the ProcInsts. Write writes it as it was for the nodes that
+	// are not changed, so that a doc is written back byte for byte. the attrs
+	// of a tag that are not changed keep their source when others are
+	Lossless bool
+	// named entities the text and the attr values may refer to, on top of the
+	// ones the internal subset of the DOCTYPE declares
+	Entities map[string]string
+	// also take the named character references of HTML5, like &nbsp; and &copy;
+	HTMLEntities bool
+	// keep a reference to a named entity in the text as an *EntityRef, that is
+	// written back as the reference instead of the text it stands for
+	KeepEntityRefs bool
+}
+
+func ParseWithOptions(r io.Reader, opts *ParseOptions) (d *Doc, err error) {
+	tap := &tapReader{r: r}
+	decoder := xml.NewDecoder(tap)
+	var o ParseOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Names == nil {
+		o.Names = NewNameTable()
+	}
+	return parse(decoder, tap, &o)
 }
 
 func ParseString(s string) (d *Doc, err error) {
@@ -214,69 +388,138 @@ func ParseBytes(bts []byte) (d *Doc, err error) {
 	return Parse(r)
 }
 
-func parse(decoder *xml.Decoder) (d *Doc, err error) {
+// parse reads the tokens of decoder, which reads tap, the positions of the nodes
+// are kept in spans if opts.Positions is set
+func parse(decoder *xml.Decoder, tap *tapReader, opts *ParseOptions) (d *Doc, err error) {
 	d = &Doc{
-		nodes: list.New(),
-		root:  nil,
+		root: nil,
+		file: opts.FileName,
 	}
 	var curEle *Ele = nil
 	var ok bool = false
+	var slab *spanSlab
+	if opts.Positions {
+		slab = new(spanSlab)
+	}
+	var texts textSlab
+	attrs := make([]*Attr, 0, 8)
+	start := Position{Line: 1, Column: 1}
+	table := opts.entityTable()
+	decoder.Entity = table
 	for token, err := decoder.RawToken(); err == nil; token, err = decoder.RawToken() {
+		end := Position{Offset: int(decoder.InputOffset())}
+		end.Line, end.Column = decoder.InputPos()
+		span := slab.new(Span{Start: start, End: end})
 		switch t := token.(type) {
 		case xml.StartElement:
-			ele := NewEle(Name(t.Name), curEle)
+			ele := NewEle(opts.Names.name(t.Name), nil)
+			ele.pos = span
+			if len(t.Attr) > 0 {
+				ele.attrs = make([]*Attr, 0, len(t.Attr))
+			}
+			attrs = attrs[:0]
 			for i := 0; i < len(t.Attr); i++ {
-				ele.SetAttr(NewAttr(Name(t.Attr[i].Name), t.Attr[i].Value))
+				a := NewAttr(opts.Names.name(t.Attr[i].Name), t.Attr[i].Value)
+				attrs = append(attrs, a)
+				ele.SetAttr(a)
+			}
+			if len(attrs) > 0 && slab != nil {
+				attrSpans(tap.bytes(start.Offset, end.Offset), start, attrs, slab)
+			}
+			if opts.Lossless {
+				ele.raw = newRawTag(ele, tap.bytes(start.Offset, end.Offset))
 			}
 			if curEle != nil {
 				addEle(curEle, ele)
 			} else {
-				tmp := d.nodes.PushBack(ele)
-				ele.syncElement(tmp)
+				addEle(d, ele)
 				if d.root != nil {
 					err = errors.New("wrong format, muilti root element")
 				}
 				d.root = ele
 			}
 			curEle = ele
+			// nobody can watch d yet, the children are not looked at as changes
+			curEle.loading = true
 		case xml.EndElement:
+			curEle.loading = false
+			if curEle.pos != nil {
+				curEle.pos.End = end
+			}
+			if curEle.raw != nil {
+				curEle.raw.end = string(tap.bytes(start.Offset, end.Offset))
+			}
 			curEle, ok = curEle.parent.(*Ele)
 			if !ok {
 				curEle = nil
 			}
 		case xml.CharData:
-			cd := NewCharData(string(t))
+			var p Iparent = d
 			if curEle != nil {
-				addCharData(curEle, cd)
-			} else {
-				tmp := d.nodes.PushBack(cd)
-				cd.syncElement(tmp)
+				p = curEle
+			}
+			if opts.KeepEntityRefs && hasRefs(tap.bytes(start.Offset, end.Offset), table) {
+				addRefs(p, tap.bytes(start.Offset, end.Offset), start, table, slab, opts.Lossless)
+				break
+			}
+			cd := NewCharData(opts.Names.text(t, &texts))
+			cd.IsCDATA = isCDATA(tap.bytes(start.Offset, end.Offset))
+			cd.pos = span
+			if opts.Lossless {
+				cd.raw = &rawText{b: string(tap.bytes(start.Offset, end.Offset)), v: cd.V}
+			}
+			addCharData(p, cd)
+			if !attached(cd) {
+				// merged into the CharData before it, like text and a CDATA section
+				last := p.getNodes().Back().(*CharData)
+				if last.pos != nil {
+					last.pos.End = end
+				}
+				last.extend(tap.bytes(start.Offset, end.Offset))
 			}
 		case xml.Comment:
 			cmt := NewComment(string(t))
+			cmt.pos = span
 			if curEle != nil {
 				addComment(curEle, cmt)
 			} else {
-				tmp := d.nodes.PushBack(cmt)
-				cmt.syncElement(tmp)
+				addComment(d, cmt)
 			}
 		case xml.ProcInst:
 			pi := NewProcInst(t.Target, string(t.Inst))
+			pi.pos = span
+			if opts.Lossless {
+				pi.raw = &rawProcInst{b: string(tap.bytes(start.Offset, end.Offset)), target: pi.Target, inst: pi.Inst}
+			}
 			if curEle != nil {
 				addProcInst(curEle, pi)
 			} else {
-				tmp := d.nodes.PushBack(pi)
-				pi.syncElement(tmp)
+				addProcInst(d, pi)
 			}
 		case xml.Directive:
+			if dt, ok := parseDocType(string(t)); ok && curEle == nil {
+				dt.pos = span
+				addDocType(d, dt)
+				table, err = withDTD(table, dt)
+				if err != nil {
+					return nil, err
+				}
+				decoder.Entity = table
+				break
+			}
 			di := NewDirective(string(t))
+			di.pos = span
 			if curEle != nil {
 				addDirective(curEle, di)
 			} else {
-				tmp := d.nodes.PushBack(di)
-				di.syncElement(tmp)
+				addDirective(d, di)
 			}
 		}
+		tap.drop(end.Offset)
+		start = end
+	}
+	for ; curEle != nil; curEle, _ = curEle.parent.(*Ele) {
+		curEle.loading = false
 	}
 	if err == io.EOF {
 		err = nil
@@ -286,10 +529,12 @@ func parse(decoder *xml.Decoder) (d *Doc, err error) {
 
 // ProcInst like : <?...?>, contains Target(string) and Inst([]byte)
 type ProcInst struct {
-	*list.Element
+	idx    int
 	Target string
 	Inst   string
 	parent Iparent
+	pos    *Span
+	raw    *rawProcInst
 }
 
 func NewProcInst(target string, inst string) *ProcInst {
@@ -302,10 +547,15 @@ func NewProcInst(target string, inst string) *ProcInst {
 
 func (p *ProcInst) Copy() Node {
 	cp := NewProcInst(p.Target, p.Inst)
+	cp.pos = p.pos
+	cp.raw = p.raw
 	return cp
 }
 
 func (p *ProcInst) Write(w io.Writer) error {
+	if ok, err := p.writeRaw(w); ok {
+		return err
+	}
 	buf := bytes.NewBuffer(make([]byte, 0, 64))
 	buf.Write([]byte("<?"))
 	buf.Write([]byte(p.Target))
@@ -317,31 +567,32 @@ func (p *ProcInst) Write(w io.Writer) error {
 
 }
 
-func (p *ProcInst) pos() *list.Element {
-	return p.Element
+func (p *ProcInst) index() int {
+	return p.idx
 }
 
-func (p *ProcInst) clearpos() {
-	p.Element = nil
-}
-
-func (p *ProcInst) syncElement(e *list.Element) {
-	p.Element = e
+func (p *ProcInst) setIndex(i int) {
+	p.idx = i
 }
 
 func (p *ProcInst) GetParent() Iparent {
 	return p.parent
 }
 
+func (p *ProcInst) setParent(e Iparent) {
+	p.parent = e
+}
+
 func (p *ProcInst) clearParent() {
 	p.parent = nil
 }
 
 // Directive like <!...>
 type Directive struct {
-	*list.Element
+	idx    int
 	V      string
 	parent Iparent
+	pos    *Span
 }
 
 func NewDirective(vl string) *Directive {
@@ -353,6 +604,7 @@ func NewDirective(vl string) *Directive {
 
 func (d *Directive) Copy() Node {
 	cp := NewDirective(d.V)
+	cp.pos = d.pos
 	return cp
 }
 
@@ -365,31 +617,32 @@ func (d *Directive) Write(w io.Writer) error {
 	return err
 }
 
-func (d *Directive) pos() *list.Element {
-	return d.Element
-}
-
-func (p *Directive) clearpos() {
-	p.Element = nil
+func (d *Directive) index() int {
+	return d.idx
 }
 
-func (p *Directive) syncElement(e *list.Element) {
-	p.Element = e
+func (p *Directive) setIndex(i int) {
+	p.idx = i
 }
 
 func (p *Directive) GetParent() Iparent {
 	return p.parent
 }
 
+func (p *Directive) setParent(e Iparent) {
+	p.parent = e
+}
+
 func (p *Directive) clearParent() {
 	p.parent = nil
 }
 
 // Comment like <!--...-->
 type Comment struct {
-	*list.Element
+	idx    int
 	V      string
 	parent Iparent
+	pos    *Span
 }
 
 func NewComment(v string) *Comment {
@@ -401,6 +654,7 @@ func NewComment(v string) *Comment {
 
 func (c *Comment) Copy() Node {
 	cp := NewComment(c.V)
+	cp.pos = c.pos
 	return cp
 }
 
@@ -413,31 +667,37 @@ func (c *Comment) Write(w io.Writer) error {
 	return err
 }
 
-func (c *Comment) pos() *list.Element {
-	return c.Element
+func (c *Comment) index() int {
+	return c.idx
 }
 
-func (p *Comment) clearpos() {
-	p.Element = nil
-}
-
-func (p *Comment) syncElement(e *list.Element) {
-	p.Element = e
+func (p *Comment) setIndex(i int) {
+	p.idx = i
 }
 
 func (p *Comment) GetParent() Iparent {
 	return p.parent
 }
 
+func (p *Comment) setParent(e Iparent) {
+	p.parent = e
+}
+
 func (p *Comment) clearParent() {
 	p.parent = nil
 }
 
 // the text in the Element
 type CharData struct {
-	*list.Element
-	V      string
-	parent Iparent
+	idx int
+	V   string
+	// write V as a CDATA section, see NewCDATA
+	IsCDATA bool
+	parent  Iparent
+	pos     *Span
+	raw     *rawText
+	// the doc whose transaction removed c, see docOf
+	from *Doc
 }
 
 func NewCharData(ctt string) *CharData {
@@ -449,41 +709,60 @@ func NewCharData(ctt string) *CharData {
 
 func (c *CharData) Copy() Node {
 	cp := NewCharData(c.V)
+	cp.IsCDATA = c.IsCDATA
+	cp.pos = c.pos
+	cp.raw = c.raw
 	return cp
 }
 
 func (c *CharData) Write(w io.Writer) error {
+	if c.raw != nil && c.raw.v == c.V {
+		_, err := io.WriteString(w, c.raw.b)
+		return err
+	}
+	if c.IsCDATA {
+		return writeCDATA(w, c.V)
+	}
 	return EscapeWithoutSpace(w, []byte(c.V))
 }
 
-func (c *CharData) pos() *list.Element {
-	return c.Element
-}
-
-func (p *CharData) clearpos() {
-	p.Element = nil
+func (c *CharData) index() int {
+	return c.idx
 }
 
-func (p *CharData) syncElement(e *list.Element) {
-	p.Element = e
+func (p *CharData) setIndex(i int) {
+	p.idx = i
 }
 
 func (p *CharData) GetParent() Iparent {
 	return p.parent
 }
 
+func (p *CharData) setParent(e Iparent) {
+	p.parent = e
+}
+
 func (p *CharData) clearParent() {
 	p.parent = nil
 }
 
 // the xml element type
 type Ele struct {
-	*list.Element
-	Name    Name
-	attrs   *list.List
-	attrMap map[Name]string
-	nodes   *list.List
+	idx   int
+	Name  Name
+	attrs []*Attr
+	// the index of each attr in attrs, made when there are many, see attrMapMin
+	attrMap map[Name]int
+	nodes   nodeList
 	parent  Iparent
+	// the children not materialized yet, by ParseLazy or PDoc.Doc
+	lazy    lazyContent
+	frozen  bool
+	loading bool
+	pos     *Span
+	raw     *rawTag
+	// the doc whose transaction removed e, see docOf
+	from *Doc
 }
 
 func (e *Ele) IterNode(f IterNodeFunc) {
@@ -493,32 +772,34 @@ func (e *Ele) IterNode(f IterNodeFunc) {
 // return false to abort iteration
 type IterAttrFunc func(attr *Attr) bool
 
-//in f, can't call e.RemoveAttrByName or e.RemoveAttrByStrName. if you need, call e.RemoveAttr
+// in f, can't call e.RemoveAttrByName or e.RemoveAttrByStrName. if you need, call e.RemoveAttr
 func (e *Ele) IterAttr(f IterAttrFunc) {
-	rt := true
-	for x := e.attrs.Front(); rt && x != nil; {
-		nxt := x.Next()
-		rt = f(x.Value.(*Attr))
-		x = nxt
+	for _, a := range e.allAttrs() {
+		if !f(a) {
+			return
+		}
 	}
 }
 
+// make a new *Ele, if parent is not nil it is added to parent as the last child
 func NewEle(name Name, parent *Ele) *Ele {
-	return &Ele{
-		Name:    name,
-		nodes:   list.New(),
-		attrMap: make(map[Name]string),
-		attrs:   list.New(),
-		parent:  parent,
+	e := &Ele{
+		Name: name,
+	}
+	if parent != nil {
+		addEle(parent, e)
 	}
+	return e
 }
 
-func (d *Ele) getNodes() *list.List {
-	return d.nodes
+func (d *Ele) getNodes() *nodeList {
+	d.load()
+	return &d.nodes
 }
 
 func (d *Ele) renewNodes() {
-	d.nodes = list.New()
+	d.lazy = nil
+	d.nodes = nil
 }
 
 func (d *Ele) ToString() string {
@@ -547,29 +828,29 @@ func (e *Ele) AddNotParsedBytes(bs []byte) error {
 	if err != nil {
 		return err
 	}
-	for x := d.Root().nodes.Front(); x != nil; {
-		next := x.Next()
-		e.AddNode(x.Value.(Node))
-		x = next
+	for _, x := range *d.Root().getNodes() {
+		e.AddNode(x)
 	}
 	return nil
 }
 
 func (e *Ele) Beautiful() {
+	mustMutable("Beautiful", e)
 	e.beautiful(0, 4)
 }
 
 func (e *Ele) beautiful(prefix, indent int) {
+	e.load()
 	if e.nodes.Len() == 1 {
-		cd, ok := e.nodes.Front().Value.(*CharData)
+		cd, ok := e.nodes.Front().(*CharData)
 		if ok {
-			cd.V = strings.TrimSpace(cd.V)
+			setText(cd, strings.TrimSpace(cd.V))
 			return
 		}
 	}
 	for x := e.nodes.Front(); x != nil; {
-		nxt := x.Next()
-		switch nd := x.Value.(type) {
+		nxt := nextSibling(x)
+		switch nd := x.(type) {
 		case *Ele:
 			nd.beautiful(prefix+indent, indent)
 			v := make([]byte, 1+prefix+indent)
@@ -577,8 +858,7 @@ func (e *Ele) beautiful(prefix, indent int) {
 			for i := 1; i < len(v); i++ {
 				v[i] = ([]byte(" "))[0]
 			}
-			cd := NewCharData(string(v))
-			e.nodes.InsertBefore(cd, x)
+			insertNode(e, x.index(), NewCharData(string(v)))
 		case *CharData:
 			v := []byte(nd.V)
 			v = bytes.TrimSpace(v)
@@ -592,13 +872,13 @@ func (e *Ele) beautiful(prefix, indent int) {
 			for i := len(v) + 1; i < len(nv); i++ {
 				nv[i] = ([]byte(" "))[0]
 			}
-			nd.V = string(nv)
+			setText(nd, string(nv))
 			if nxt != nil {
-				ee, ok := nxt.Value.(*Ele)
+				ee, ok := nxt.(*Ele)
 				if ok {
 					ee.beautiful(prefix+indent, indent)
 				}
-				nxt = nxt.Next()
+				nxt = nextSibling(nxt)
 			}
 		default:
 			v := make([]byte, 1+prefix+indent)
@@ -606,13 +886,12 @@ func (e *Ele) beautiful(prefix, indent int) {
 			for i := 1; i < len(v); i++ {
 				v[i] = ([]byte(" "))[0]
 			}
-			cd := NewCharData(string(v))
-			e.nodes.InsertBefore(cd, x)
+			insertNode(e, x.index(), NewCharData(string(v)))
 		}
 		x = nxt
 	}
 	if e.nodes.Len() > 0 {
-		_, ok := e.nodes.Back().Value.(*CharData)
+		_, ok := e.nodes.Back().(*CharData)
 		if ok {
 			return
 		}
@@ -621,14 +900,16 @@ func (e *Ele) beautiful(prefix, indent int) {
 		for i := 1; i < len(v); i++ {
 			v[i] = ([]byte(" "))[0]
 		}
-		cd := NewCharData(string(v))
-		e.nodes.PushBack(cd)
+		pushNode(e, NewCharData(string(v)))
 	}
 }
 
 func (e *Ele) Write(w io.Writer) error {
-	if e.nodes.Len() > 0 {
-		_, err := w.Write([]byte("<"))
+	if e.raw != nil && e.raw.valid(e) {
+		return e.writeRaw(w)
+	}
+	if e.nodes.Len() > 0 || e.lazy != nil {
+		_, err := io.WriteString(w, "<")
 		if err != nil {
 			return err
 		}
@@ -637,44 +918,48 @@ func (e *Ele) Write(w io.Writer) error {
 			return err
 		}
 
-		for x := e.attrs.Front(); x != nil; x = x.Next() {
-			_, err = w.Write([]byte(" "))
+		for _, attr := range e.attrs {
+			_, err = io.WriteString(w, " ")
 			if err != nil {
 				return err
 			}
-			attr := x.Value.(*Attr)
 			nm := Name(attr.Name)
 			err = nm.Write(w)
 			if err != nil {
 				return err
 			}
-			_, err = w.Write([]byte("=\""))
+			_, err = io.WriteString(w, "=\"")
 			if err != nil {
 				return err
 			}
-			_, err = w.Write([]byte(attr.Value))
+			err = EscapeWithoutSpace(w, []byte(attr.Value))
 			if err != nil {
 				return err
 			}
-			_, err = w.Write([]byte("\""))
+			_, err = io.WriteString(w, "\"")
 			if err != nil {
 				return err
 			}
 		}
-		_, err = w.Write([]byte(">"))
+		_, err = io.WriteString(w, ">")
 		if err != nil {
 			return err
 		}
 
-		for x := e.nodes.Front(); x != nil; x = x.Next() {
-			n := x.Value.(Node)
+		if e.lazy != nil {
+			err = e.lazy.write(w)
+			if err != nil {
+				return err
+			}
+		}
+		for _, n := range e.nodes {
 			err = n.Write(w)
 			if err != nil {
 				return err
 			}
 		}
 
-		_, err = w.Write([]byte("</"))
+		_, err = io.WriteString(w, "</")
 		if err != nil {
 			return err
 		}
@@ -683,12 +968,12 @@ func (e *Ele) Write(w io.Writer) error {
 		if err != nil {
 			return err
 		}
-		_, err = w.Write([]byte(">"))
+		_, err = io.WriteString(w, ">")
 		if err != nil {
 			return err
 		}
 	} else {
-		_, err := w.Write([]byte("<"))
+		_, err := io.WriteString(w, "<")
 		if err != nil {
 			return err
 		}
@@ -698,31 +983,30 @@ func (e *Ele) Write(w io.Writer) error {
 			return err
 		}
 
-		for x := e.attrs.Front(); x != nil; x = x.Next() {
-			_, err = w.Write([]byte(" "))
+		for _, attr := range e.attrs {
+			_, err = io.WriteString(w, " ")
 			if err != nil {
 				return err
 			}
-			attr := x.Value.(*Attr)
 			nm := Name(attr.Name)
 			err = nm.Write(w)
 			if err != nil {
 				return err
 			}
-			_, err = w.Write([]byte("=\""))
+			_, err = io.WriteString(w, "=\"")
 			if err != nil {
 				return err
 			}
-			_, err = w.Write([]byte(attr.Value))
+			err = EscapeWithoutSpace(w, []byte(attr.Value))
 			if err != nil {
 				return err
 			}
-			_, err = w.Write([]byte("\""))
+			_, err = io.WriteString(w, "\"")
 			if err != nil {
 				return err
 			}
 		}
-		_, err = w.Write([]byte("/>"))
+		_, err = io.WriteString(w, "/>")
 		if err != nil {
 			return err
 		}
@@ -730,21 +1014,33 @@ func (e *Ele) Write(w io.Writer) error {
 	return nil
 }
 
-func (e *Ele) SetAttr(attr *Attr) {
-	_, ok := e.attrMap[Name(attr.Name)]
-	if ok {
-		e.attrMap[Name(attr.Name)] = attr.Value
-		for x := e.attrs.Front(); x != nil; x = x.Next() {
-			a := x.Value.(Attr)
-			if a.Name == attr.Name {
-				x.Value = attr
-				break
-			}
+// elements with more attrs than this get an attr map, fewer are searched one by one
+const attrMapMin = 8
+
+// return the index of the attr with the name in e.attrs, -1 if there is none
+func (e *Ele) attrIndex(name Name) int {
+	if e.attrMap != nil {
+		i, ok := e.attrMap[name]
+		if !ok {
+			return -1
+		}
+		return i
+	}
+	for i, a := range e.attrs {
+		if a.Name == name {
+			return i
 		}
+	}
+	return -1
+}
+
+func (e *Ele) SetAttr(attr *Attr) {
+	mustMutable("SetAttr", e)
+	i := e.attrIndex(attr.Name)
+	if i >= 0 {
+		replaceAttrAt(e, i, attr)
 	} else {
-		e.attrMap[attr.Name] = attr.Value
-		tmp := e.attrs.PushBack(attr)
-		attr.Element = tmp
+		insertAttrAt(e, len(e.attrs), attr)
 	}
 }
 
@@ -753,24 +1049,20 @@ func (e *Ele) RemoveAttrByStrName(space, local string) (string, bool) {
 }
 
 func (e *Ele) RemoveAttrByName(name Name) (string, bool) {
-	rt, ok := e.attrMap[name]
-	delete(e.attrMap, name)
-	for x := e.attrs.Front(); ok && x != nil; x = x.Next() {
-		attr := x.Value.(*Attr)
-		if attr.Name == name {
-			e.attrs.Remove(x)
-			attr.Element = nil
-			break
-		}
+	mustMutable("RemoveAttr", e)
+	i := e.attrIndex(name)
+	if i < 0 {
+		return "", false
 	}
+	rt, ok := e.GetAttr(name)
+	deleteAttrAt(e, i)
 	return rt, ok
 }
 
 func (e *Ele) RemoveAttr(attr *Attr) {
-	_, ok := e.attrMap[attr.Name]
-	delete(e.attrMap, attr.Name)
-	if ok {
-		e.attrs.Remove(attr.Element)
+	i := e.attrIndex(attr.Name)
+	if i >= 0 && e.attrs[i] == attr {
+		e.RemoveAttrByName(attr.Name)
 	}
 }
 
@@ -779,49 +1071,60 @@ func (e *Ele) GetAttrByStrName(space, local string) (string, bool) {
 }
 
 func (e *Ele) GetAttr(name Name) (string, bool) {
-	rt, ok := e.attrMap[name]
-	return rt, ok
+	i := e.attrIndex(name)
+	if i < 0 {
+		return "", false
+	}
+	return e.attrs[i].Value, true
+}
+
+// return the *Attr name of e, nil if there is none
+func (e *Ele) GetAttrNode(name Name) *Attr {
+	i := e.attrIndex(name)
+	if i < 0 {
+		return nil
+	}
+	return e.attrs[i]
 }
 
 func (e *Ele) Copy() Node {
 	cp := NewEle(e.Name, nil)
-	for x := e.attrs.Front(); x != nil; x = x.Next() {
-		a := x.Value.(*Attr)
+	// the copy keeps the source positions, they are never changed
+	cp.pos = e.pos
+	cp.raw = e.raw
+	cp.attrs = make([]*Attr, 0, len(e.attrs))
+	for _, a := range e.attrs {
 		na := NewAttr(a.Name, a.Value)
+		na.pos = a.pos
 		cp.SetAttr(na)
 	}
-	for x := e.nodes.Front(); x != nil; x = x.Next() {
-		switch n := x.Value.(type) {
-		case *Ele:
-			cpn := n.Copy().(*Ele)
-			cpn.parent = cp
-			tmp := cp.nodes.PushBack(cpn)
-			cpn.syncElement(tmp)
-		case Node:
-			cpn := n.Copy()
-			tmp := cp.nodes.PushBack(cpn)
-			cpn.syncElement(tmp)
-		}
+	// the lazy content is never changed, the copy can share it
+	cp.lazy = e.lazy
+	cp.nodes = make(nodeList, 0, len(e.nodes))
+	for _, n := range e.nodes {
+		cpn := n.Copy()
+		cpn.setParent(cp)
+		cp.nodes.PushBack(cpn)
 	}
 	return cp
 }
 
-func (e *Ele) pos() *list.Element {
-	return e.Element
+func (e *Ele) index() int {
+	return e.idx
 }
 
-func (e *Ele) clearpos() {
-	e.Element = nil
-}
-
-func (p *Ele) syncElement(e *list.Element) {
-	p.Element = e
+func (e *Ele) setIndex(i int) {
+	e.idx = i
 }
 
 func (p *Ele) GetParent() Iparent {
 	return p.parent
 }
 
+func (p *Ele) setParent(e Iparent) {
+	p.parent = e
+}
+
 func (p *Ele) clearParent() {
 	p.parent = nil
 }
@@ -838,8 +1141,10 @@ func (e *Ele) AddNode(n Node) error {
 		e.AddCharData(nd)
 	case *ProcInst:
 		e.AddProcInst(nd)
+	case *EntityRef:
+		e.AddEntityRef(nd)
 	default:
-		return errors.New("not a node")
+		return treeErr("AddNode", n, ErrNotNode)
 	}
 	return nil
 }
@@ -850,9 +1155,9 @@ func (e *Ele) AddEle(ele *Ele) {
 }
 
 func addEle(e Iparent, ele *Ele) {
-	ele.parent = e
-	tmp := e.getNodes().PushBack(ele)
-	ele.syncElement(tmp)
+	mustMutable("AddEle", e)
+	detach(ele)
+	pushNode(e, ele)
 }
 
 func (e *Ele) AddDirective(d *Directive) {
@@ -860,9 +1165,8 @@ func (e *Ele) AddDirective(d *Directive) {
 }
 
 func addDirective(e Iparent, d *Directive) {
-	d.parent = e
-	tmp := e.getNodes().PushBack(d)
-	d.syncElement(tmp)
+	mustMutable("AddDirective", e)
+	pushNode(e, d)
 }
 
 func (e *Ele) AddComment(c *Comment) {
@@ -870,35 +1174,39 @@ func (e *Ele) AddComment(c *Comment) {
 }
 
 func addComment(e Iparent, c *Comment) {
-	c.parent = e
-	tmp := e.getNodes().PushBack(c)
-	c.syncElement(tmp)
+	mustMutable("AddComment", e)
+	pushNode(e, c)
 }
 
 func (e *Ele) AddCharData(c *CharData) {
 	addCharData(e, c.Copy().(*CharData))
 }
 
-func (e *Ele) AddCharDataStr(s string) {
+// add s as text, AsCDATA adds it as a CDATA section
+func (e *Ele) AddCharDataStr(s string, opts ...TextOption) {
 	c := NewCharData(s)
+	for _, o := range opts {
+		if o == AsCDATA {
+			c.IsCDATA = true
+		}
+	}
 	addCharData(e, c)
 }
 
 func addCharData(e Iparent, c *CharData) {
-	c.parent = e
+	mustMutable("AddCharData", e)
 	var last *CharData = nil
 	ok := false
 	if e.getNodes().Back() != nil {
 		ok = true
 	}
 	if ok {
-		last, ok = e.getNodes().Back().Value.(*CharData)
+		last, ok = mergeable(e.getNodes().Back(), c)
 	}
 	if ok {
 		mergeinto1st(last, c)
 	} else {
-		tmp := e.getNodes().PushBack(c)
-		c.syncElement(tmp)
+		pushNode(e, c)
 	}
 }
 
@@ -907,77 +1215,90 @@ func (e *Ele) AddProcInst(p *ProcInst) {
 }
 
 func addProcInst(e Iparent, p *ProcInst) {
-	p.parent = e
-	tmp := e.getNodes().PushBack(p)
-	p.syncElement(tmp)
+	mustMutable("AddProcInst", e)
+	pushNode(e, p)
+}
+
+// mergeable returns n as a *CharData c can be merged with, a CDATA section only
+// merges with a CDATA section
+func mergeable(n Node, c *CharData) (*CharData, bool) {
+	cd, ok := n.(*CharData)
+	return cd, ok && c != nil && cd.IsCDATA == c.IsCDATA
 }
 
 func mergeinto1st(c1, c2 *CharData) {
-	s := strings.Join([]string{c1.V, c2.V}, "")
-	c1.V = s
-	if c2.pos() != nil {
-		c2.parent.getNodes().Remove(c2.pos())
+	setText(c1, c1.V+c2.V)
+	if attached(c2) {
+		removeAt(c2.parent, c2.index())
 	}
 }
 
 func insertBefore(e Iparent, n Node, npos Node) error {
 	if !checkIsNode(n) {
-		return errors.New("not a node")
+		return treeErr("InsertBefore", n, ErrNotNode)
 	}
-	pos := npos.pos()
+	if err := frozenErr("InsertBefore", n, e); err != nil {
+		return err
+	}
+	if npos.GetParent() != e || !attached(npos) {
+		return treeErr("InsertBefore", npos, ErrNotChild)
+	}
+	pos := npos.index()
 	var bf *CharData = nil
 	ok2 := false
-	if pos.Prev() != nil {
+	if pos > 0 {
 		ok2 = true
 	}
 
 	cd, ok1 := n.(*CharData)
 	if ok2 {
-		bf, ok2 = pos.Prev().Value.(*CharData)
+		bf, ok2 = mergeable(e.getNodes().at(pos-1), cd)
 	}
-	af, ok3 := pos.Value.(*CharData)
+	af, ok3 := mergeable(npos, cd)
 	if !ok1 || (!ok2 && !ok3) {
-		nc := n.Copy()
-		tmp := e.getNodes().InsertBefore(nc, pos)
-		nc.syncElement(tmp)
+		insertNode(e, pos, n.Copy())
 	} else if ok1 && ok2 {
 		mergeinto1st(bf, cd)
-	} else if ok2 && ok3 {
-		mergeinto1st(cd, af)
+	} else if ok1 && ok3 {
+		setText(af, cd.V+af.V)
 	} else {
 		return errors.New("should never got this")
 	}
 	return nil
 }
 
-// n is the node to insert, pos is the *list.Element holding a node which is in e
+// n is the node to insert, pos is a node which is in e
 func (e *Ele) InsertBefore(n Node, pos Node) error {
 	return insertBefore(e, n, pos)
 }
 
 func insertAfter(e Iparent, n Node, npos Node) error {
 	if !checkIsNode(n) {
-		return errors.New("not a node")
+		return treeErr("InsertAfter", n, ErrNotNode)
 	}
-	pos := npos.pos()
+	if err := frozenErr("InsertAfter", n, e); err != nil {
+		return err
+	}
+	if npos.GetParent() != e || !attached(npos) {
+		return treeErr("InsertAfter", npos, ErrNotChild)
+	}
+	pos := npos.index()
 	cd, ok1 := n.(*CharData)
-	bf, ok2 := pos.Value.(*CharData)
+	bf, ok2 := mergeable(npos, cd)
 	var af *CharData = nil
 	ok3 := false
-	if pos.Next() != nil {
+	if pos+1 < e.getNodes().Len() {
 		ok3 = true
 	}
 	if ok3 {
-		af, ok3 = pos.Next().Value.(*CharData)
+		af, ok3 = mergeable(e.getNodes().at(pos+1), cd)
 	}
 	if !ok1 || (!ok2 && !ok3) {
-		nc := n.Copy()
-		tmp := e.getNodes().InsertAfter(nc, pos)
-		nc.syncElement(tmp)
+		insertNode(e, pos+1, n.Copy())
 	} else if ok1 && ok2 {
 		mergeinto1st(bf, cd)
-	} else if ok2 && ok3 {
-		mergeinto1st(cd, af)
+	} else if ok1 && ok3 {
+		setText(af, cd.V+af.V)
 	} else {
 		return errors.New("should never got this")
 	}
@@ -985,7 +1306,7 @@ func insertAfter(e Iparent, n Node, npos Node) error {
 	return nil
 }
 
-// n is the node to insert, pos is the *list.Element holding a node which is in e
+// n is the node to insert, pos is a node which is in e
 func (e *Ele) InsertAfter(n Node, pos Node) error {
 	return insertAfter(e, n, pos)
 }
@@ -996,8 +1317,8 @@ func (e *Ele) AllEles() []*Ele {
 
 func allEles(e Iparent) []*Ele {
 	rt := make([]*Ele, 0, e.getNodes().Len())
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		n, ok := x.Value.(*Ele)
+	for _, x := range *e.getNodes() {
+		n, ok := x.(*Ele)
 		if ok {
 			rt = append(rt, n)
 		}
@@ -1005,26 +1326,6 @@ func allEles(e Iparent) []*Ele {
 	return rt
 }
 
-func myappendEle(sth *[]*Ele, x *Ele) {
-	if len(*sth) < cap(*sth) {
-		*sth = append(*sth, x)
-	} else {
-		extendEle(sth)
-		*sth = append(*sth, x)
-	}
-}
-
-func extendEle(sth *[]*Ele) {
-	l := cap(*sth)
-	nl := l * 2
-	if nl > 128 {
-		nl = l + 16
-	}
-	nsth := make([]*Ele, l, nl)
-	copy(nsth, *sth)
-	*sth = nsth
-}
-
 func (e *Ele) ElesByStrName(space, local string) []*Ele {
 	return e.Eles(NewName(space, local))
 }
@@ -1035,150 +1336,70 @@ func (e *Ele) Eles(name Name) []*Ele {
 
 func eles(e Iparent, name Name) []*Ele {
 	rt := make([]*Ele, 0, 4)
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		se, ok := x.Value.(*Ele)
+	for _, x := range *e.getNodes() {
+		se, ok := x.(*Ele)
 		if ok && se.Name == name {
-			myappendEle(&rt, se)
+			rt = append(rt, se)
 		}
 	}
 	return rt
 }
 
-func myappendComment(sth *[]*Comment, x *Comment) {
-	if len(*sth) < cap(*sth) {
-		*sth = append(*sth, x)
-	} else {
-		extendComment(sth)
-		*sth = append(*sth, x)
-	}
-}
-
-func extendComment(sth *[]*Comment) {
-	l := cap(*sth)
-	nl := l * 2
-	if nl > 128 {
-		nl = l + 16
-	}
-	nsth := make([]*Comment, l, nl)
-	copy(nsth, *sth)
-	*sth = nsth
-}
-
 func (e *Ele) AllComments() []*Comment {
 	return allComments(e)
 }
 
 func allComments(e Iparent) []*Comment {
 	rt := make([]*Comment, 0, 4)
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		cmt, ok := x.Value.(*Comment)
+	for _, x := range *e.getNodes() {
+		cmt, ok := x.(*Comment)
 		if ok {
-			myappendComment(&rt, cmt)
+			rt = append(rt, cmt)
 		}
 	}
 	return rt
 }
 
-func myappendDirective(sth *[]*Directive, x *Directive) {
-	if len(*sth) < cap(*sth) {
-		*sth = append(*sth, x)
-	} else {
-		extendDirective(sth)
-		*sth = append(*sth, x)
-	}
-}
-
-func extendDirective(sth *[]*Directive) {
-	l := cap(*sth)
-	nl := l * 2
-	if nl > 128 {
-		nl = l + 16
-	}
-	nsth := make([]*Directive, l, nl)
-	copy(nsth, *sth)
-	*sth = nsth
-}
-
 func (e *Ele) AllDirectives() []*Directive {
 	return allDirectives(e)
 }
 
 func allDirectives(e Iparent) []*Directive {
 	rt := make([]*Directive, 0, 4)
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		d, ok := x.Value.(*Directive)
+	for _, x := range *e.getNodes() {
+		d, ok := x.(*Directive)
 		if ok {
-			myappendDirective(&rt, d)
+			rt = append(rt, d)
 		}
 	}
 	return rt
 }
 
-func myappendProcInst(sth *[]*ProcInst, x *ProcInst) {
-	if len(*sth) < cap(*sth) {
-		*sth = append(*sth, x)
-	} else {
-		extendProcInst(sth)
-		*sth = append(*sth, x)
-	}
-}
-
-func extendProcInst(sth *[]*ProcInst) {
-	l := cap(*sth)
-	nl := l * 2
-	if nl > 128 {
-		nl = l + 16
-	}
-	nsth := make([]*ProcInst, l, nl)
-	copy(nsth, *sth)
-	*sth = nsth
-}
-
 func (e *Ele) AllProcInsts() []*ProcInst {
 	return allProcInsts(e)
 }
 
 func allProcInsts(e Iparent) []*ProcInst {
 	rt := make([]*ProcInst, 0, 4)
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		p, ok := x.Value.(*ProcInst)
+	for _, x := range *e.getNodes() {
+		p, ok := x.(*ProcInst)
 		if ok {
-			myappendProcInst(&rt, p)
+			rt = append(rt, p)
 		}
 	}
 	return rt
 }
 
-func myappendCharData(sth *[]*CharData, x *CharData) {
-	if len(*sth) < cap(*sth) {
-		*sth = append(*sth, x)
-	} else {
-		extendCharData(sth)
-		*sth = append(*sth, x)
-	}
-}
-
-func extendCharData(sth *[]*CharData) {
-	l := cap(*sth)
-	nl := l * 2
-	if nl > 128 {
-		nl = l + 16
-	}
-	nsth := make([]*CharData, l, nl)
-	copy(nsth, *sth)
-	*sth = nsth
-}
-
 func (e *Ele) AllCharData() []*CharData {
 	return allCharData(e)
 }
 
 func allCharData(e Iparent) []*CharData {
 	rt := make([]*CharData, 0, 4)
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		c, ok := x.Value.(*CharData)
+	for _, x := range *e.getNodes() {
+		c, ok := x.(*CharData)
 		if ok {
-			myappendCharData(&rt, c)
+			rt = append(rt, c)
 		}
 	}
 	return rt
@@ -1190,10 +1411,12 @@ func (e *Ele) Text() string {
 
 func text(e Iparent) string {
 	buf := make([]string, 0, e.getNodes().Len()/2+1)
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		cd, ok := x.Value.(*CharData)
-		if ok {
-			buf = append(buf, cd.V)
+	for _, x := range *e.getNodes() {
+		switch t := x.(type) {
+		case *CharData:
+			buf = append(buf, t.V)
+		case *EntityRef:
+			buf = append(buf, t.Value)
 		}
 	}
 	return strings.Join(buf, "")
@@ -1205,10 +1428,12 @@ func (e *Ele) TrimedText() string {
 
 func trimedText(e Iparent) string {
 	buf := make([]string, 0, e.getNodes().Len()/2+1)
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		cd, ok := x.Value.(*CharData)
-		if ok {
-			buf = append(buf, strings.TrimSpace(cd.V))
+	for _, x := range *e.getNodes() {
+		switch t := x.(type) {
+		case *CharData:
+			buf = append(buf, strings.TrimSpace(t.V))
+		case *EntityRef:
+			buf = append(buf, strings.TrimSpace(t.Value))
 		}
 	}
 	return strings.Join(buf, "")
@@ -1226,10 +1451,13 @@ func checkIsNode(n interface{}) bool {
 		return true
 	case *Directive:
 		return true
+	case *DocType:
+		return true
+	case *EntityRef:
+		return true
 	default:
 		return false
 	}
-	return false
 }
 
 func (e *Ele) RemoveNode(n Node) error {
@@ -1238,12 +1466,15 @@ func (e *Ele) RemoveNode(n Node) error {
 
 func removeNode(e Iparent, n Node) error {
 	if !checkIsNode(n) {
-		return errors.New("not a node")
+		return treeErr("RemoveNode", n, ErrNotNode)
+	}
+	if err := frozenErr("RemoveNode", n, e); err != nil {
+		return err
 	}
-	nd := n.pos()
-	e.getNodes().Remove(nd)
-	n.clearpos()
-	n.clearParent()
+	if n.GetParent() != e || !attached(n) {
+		return treeErr("RemoveNode", n, ErrNotChild)
+	}
+	removeAt(e, n.index())
 	return nil
 }
 
@@ -1252,16 +1483,11 @@ func (e *Ele) RemoveAllEle() {
 }
 
 func removeAllEle(e Iparent) {
-	for x := e.getNodes().Front(); x != nil; {
-		next := x.Next()
-		ele, ok := x.Value.(*Ele)
-		if ok {
-			e.getNodes().Remove(x)
-			ele.clearpos()
-			ele.clearParent()
-		}
-		x = next
-	}
+	mustMutable("RemoveAll", e)
+	removeNodesIf(e, func(n Node) bool {
+		_, ok := n.(*Ele)
+		return ok
+	})
 }
 
 func (e *Ele) RemoveAllDirective() {
@@ -1269,16 +1495,11 @@ func (e *Ele) RemoveAllDirective() {
 }
 
 func removeAllDirective(e Iparent) {
-	for x := e.getNodes().Front(); x != nil; {
-		next := x.Next()
-		d, ok := x.Value.(*Directive)
-		if ok {
-			e.getNodes().Remove(x)
-			d.clearpos()
-			d.clearParent()
-		}
-		x = next
-	}
+	mustMutable("RemoveAll", e)
+	removeNodesIf(e, func(n Node) bool {
+		_, ok := n.(*Directive)
+		return ok
+	})
 }
 
 func (e *Ele) RemoveAllComment() {
@@ -1286,16 +1507,11 @@ func (e *Ele) RemoveAllComment() {
 }
 
 func removeAllComment(e Iparent) {
-	for x := e.getNodes().Front(); x != nil; {
-		next := x.Next()
-		c, ok := x.Value.(*Comment)
-		if ok {
-			e.getNodes().Remove(x)
-			c.clearpos()
-			c.clearParent()
-		}
-		x = next
-	}
+	mustMutable("RemoveAll", e)
+	removeNodesIf(e, func(n Node) bool {
+		_, ok := n.(*Comment)
+		return ok
+	})
 }
 
 func (e *Ele) RemoveAllCharData() {
@@ -1303,15 +1519,31 @@ func (e *Ele) RemoveAllCharData() {
 }
 
 func removeAllCharDate(e Iparent) {
-	for x := e.getNodes().Front(); x != nil; {
-		next := x.Next()
-		c, ok := x.Value.(*CharData)
-		if ok {
-			e.getNodes().Remove(x)
-			c.clearpos()
-			c.clearParent()
+	mustMutable("RemoveAll", e)
+	removeNodesIf(e, func(n Node) bool {
+		_, ok := n.(*CharData)
+		return ok
+	})
+}
+
+// replaceText puts copies of the CharData and EntityRef of src in the place of
+// those of e, after the other children of e
+func replaceText(e *Ele, src *Ele) {
+	mustMutable("ReplaceText", e)
+	removeNodesIf(e, func(n Node) bool {
+		switch n.(type) {
+		case *CharData, *EntityRef:
+			return true
+		}
+		return false
+	})
+	for _, n := range *src.getNodes() {
+		switch t := n.(type) {
+		case *CharData:
+			e.AddCharData(t)
+		case *EntityRef:
+			e.AddEntityRef(t)
 		}
-		x = next
 	}
 }
 
@@ -1320,45 +1552,37 @@ func (e *Ele) RemoveAllProcInst() {
 }
 
 func removeAllProcInst(e Iparent) {
-	for x := e.getNodes().Front(); x != nil; {
-		next := x.Next()
-		p, ok := x.Value.(*ProcInst)
-		if ok {
-			e.getNodes().Remove(x)
-			p.clearpos()
-			p.clearParent()
-		}
-		x = next
-	}
+	mustMutable("RemoveAll", e)
+	removeNodesIf(e, func(n Node) bool {
+		_, ok := n.(*ProcInst)
+		return ok
+	})
 }
 
 func (e *Ele) RemoveAllNodes() {
-	for x := e.nodes.Front(); x != nil; x = x.Next() {
-		x.Value.(Node).clearParent()
-		x.Value.(Node).clearpos()
-	}
-	e.nodes = list.New()
+	removeAllNodes(e)
 }
 
 func removeAllNodes(e Iparent) {
-	for x := e.getNodes().Front(); x != nil; x = x.Next() {
-		x.Value.(Node).clearParent()
-		x.Value.(Node).clearpos()
+	mustMutable("RemoveAll", e)
+	if d, _ := docOf(e); d != nil && d.hooked() {
+		// one by one, for the transaction
+		removeNodesIf(e, func(Node) bool { return true })
+		return
+	}
+	for _, x := range *e.getNodes() {
+		x.clearParent()
+		x.setIndex(-1)
 	}
 	e.renewNodes()
 }
 
 func (e *Ele) RemoveEleByName(name Name) {
-	for x := e.nodes.Front(); x != nil; {
-		next := x.Next()
-		ele, ok := x.Value.(*Ele)
-		if ok && ele.Name == name {
-			ele.clearParent()
-			e.nodes.Remove(x)
-			ele.clearpos()
-		}
-		x = next
-	}
+	mustMutable("RemoveEle", e)
+	removeNodesIf(e, func(n Node) bool {
+		ele, ok := n.(*Ele)
+		return ok && ele.Name == name
+	})
 }
 
 func (e *Ele) RemoveEleByStrName(space, local string) {
@@ -1367,17 +1591,21 @@ func (e *Ele) RemoveEleByStrName(space, local string) {
 }
 
 func (e *Ele) AllNodes() []Node {
-	rt := make([]Node, 0, e.nodes.Len())
-	for x := e.nodes.Front(); x != nil; x = x.Next() {
-		rt = append(rt, x.Value.(Node))
-	}
-	return rt
+	return allNodes(e)
+}
+
+func allNodes(e Iparent) []Node {
+	return append([]Node(nil), *e.getNodes()...)
+}
+
+func (e *Ele) allAttrs() []*Attr {
+	return append([]*Attr(nil), e.attrs...)
 }
 
 type Attr struct {
-	*list.Element
 	Name  Name
 	Value string
+	pos   *Span
 }
 
 func NewAttr(name Name, value string) *Attr {
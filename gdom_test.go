@@ -74,7 +74,7 @@ func TestAdd(t *testing.T) {
 		return
 	}
 	xb := xbs[0]
-	v, ok := xb.Value.(*Ele).GetAttr(NewName("", "a"))
+	v, ok := xb.GetAttr(NewName("", "a"))
 	if !ok || v != "xxx" {
 		t.Error("wrong attr value")
 	}
@@ -0,0 +1,3 @@
+module github.com/xxy84/gdom/v2
+
+go 1.23
@@ -0,0 +1,103 @@
+package gdom
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotNode  = errors.New("not a node")
+	ErrNotChild = errors.New("node is not a child of the parent")
+	ErrDetached = errors.New("node has no parent")
+	ErrCycle    = errors.New("node would become its own descendant")
+	ErrRoot     = errors.New("the document must keep one root element")
+	ErrCorrupt  = errors.New("corrupt tree")
+	ErrFrozen   = errors.New("doc is frozen")
+)
+
+// TreeError is returned by the mutations refusing to break the tree and by
+// Doc.Check, Err is one of the Err values above
+type TreeError struct {
+	Op   string
+	Node Node
+	Err  error
+	// what Check found broken
+	Detail string
+}
+
+func (e *TreeError) Error() string {
+	s := "gdom: " + e.Op + ": " + e.Err.Error()
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+func (e *TreeError) Unwrap() error {
+	return e.Err
+}
+
+func treeErr(op string, n Node, err error) error {
+	return &TreeError{Op: op, Node: n, Err: err}
+}
+
+func corrupt(n Node, detail string) error {
+	return &TreeError{Op: "Check", Node: n, Err: ErrCorrupt, Detail: detail}
+}
+
+// Check verifies the bookkeeping of the doc: every node is held by the list of
+// its parent and only once, its parent and index point back to where it
+// is, the attrs of every element agree with each other, and the doc has one root
+// element that is the one Root returns
+func (d *Doc) Check() error {
+	var root *Ele
+	for _, x := range d.nodes {
+		e, ok := x.(*Ele)
+		if !ok {
+			continue
+		}
+		if root != nil {
+			return corrupt(e, "more than one root element")
+		}
+		root = e
+	}
+	if root == nil || root != d.root {
+		return corrupt(d.root, "root is not the element of the doc")
+	}
+	return checkNodes(d, d.nodes, make(map[Node]bool))
+}
+
+func checkNodes(p Iparent, l nodeList, seen map[Node]bool) error {
+	for i, n := range l {
+		if !checkIsNode(n) {
+			return corrupt(nil, "list holds a value that is not a node")
+		}
+		if seen[n] {
+			return corrupt(n, "node is in the tree twice")
+		}
+		seen[n] = true
+		if n.index() != i {
+			return corrupt(n, "index of the node is wrong")
+		}
+		if n.GetParent() != p {
+			return corrupt(n, "parent of the node is wrong")
+		}
+		e, ok := n.(*Ele)
+		if !ok {
+			continue
+		}
+		if e.attrMap != nil && len(e.attrs) != len(e.attrMap) {
+			return corrupt(e, "attrs and attr map differ")
+		}
+		for i, attr := range e.attrs {
+			v, ok := e.GetAttr(attr.Name)
+			if !ok || v != attr.Value || e.attrIndex(attr.Name) != i {
+				return corrupt(e, "attr "+nameString(attr.Name)+" is inconsistent")
+			}
+		}
+		err := checkNodes(e, e.nodes, seen)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
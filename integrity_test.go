@@ -0,0 +1,53 @@
+package gdom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	d, _ := ParseString(`<?pi x?><p a="1"><a><b/></a>t<!--c--></p>`)
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+	cp := d.Copy()
+	cp.Beautiful()
+	if err := cp.Check(); err != nil {
+		t.Error(err)
+	}
+	a := d.Root().ElesByStrName("", "a")[0]
+	b := a.ElesByStrName("", "b")[0]
+	b.parent = d.Root()
+	err := d.Check()
+	if !errors.Is(err, ErrCorrupt) {
+		t.Error("wrong parent not found", err)
+	}
+}
+
+func TestMutationIntegrity(t *testing.T) {
+	d, _ := ParseString(`<p><a><b/></a><c/></p>`)
+	r := d.Root()
+	a := r.ElesByStrName("", "a")[0]
+	b := a.ElesByStrName("", "b")[0]
+	c := r.ElesByStrName("", "c")[0]
+	if err := MoveBefore(a, b); !errors.Is(err, ErrCycle) {
+		t.Error("cycle not rejected", err)
+	}
+	if err := r.InsertBefore(NewComment("x"), b); !errors.Is(err, ErrNotChild) {
+		t.Error("insert before a grandchild not rejected", err)
+	}
+	if err := r.RemoveNode(b); !errors.Is(err, ErrNotChild) {
+		t.Error("remove of a grandchild not rejected", err)
+	}
+	n := NewEle(NewName("", "n"), c)
+	if n.ParentEle() != c || c.FirstChild() != Node(n) {
+		t.Error("NewEle with parent not attached")
+	}
+	d.SetRoot(b)
+	if d.Root() != b || len(a.AllEles()) != 0 || d.ToString() != "<b/>" {
+		t.Error("set root failed", d.ToString())
+	}
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+}
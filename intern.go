@@ -0,0 +1,99 @@
+package gdom
+
+import (
+	"encoding/xml"
+	"sync"
+	"unsafe"
+)
+
+// whitespace only text longer than this is not interned
+const internTextMax = 64
+
+// NameTable interns the strings of element and attr names and of the whitespace
+// between elements, so equal names of a doc, or of all the docs parsed with the
+// same table, share one string. it is safe for concurrent parses
+type NameTable struct {
+	mu   sync.Mutex
+	strs map[string]string
+}
+
+func NewNameTable() *NameTable {
+	return &NameTable{
+		strs: make(map[string]string, 64),
+	}
+}
+
+// Intern returns the string of the table equal to s, adding s if there is none
+func (t *NameTable) Intern(s string) string {
+	t.mu.Lock()
+	rt, ok := t.strs[s]
+	if !ok {
+		t.strs[s] = s
+		rt = s
+	}
+	t.mu.Unlock()
+	return rt
+}
+
+// return the number of strings in the table
+func (t *NameTable) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.strs)
+}
+
+func (t *NameTable) name(n xml.Name) Name {
+	if n.Space != "" {
+		n.Space = t.Intern(n.Space)
+	}
+	n.Local = t.Intern(n.Local)
+	return Name(n)
+}
+
+// text returns b as a string, whitespace only text is interned since indentation
+// repeats all over a doc, the other text is made in texts. b is not kept, the
+// decoder reuses its buffer
+func (t *NameTable) text(b []byte, texts *textSlab) string {
+	if len(b) > internTextMax || !isSpace(b) {
+		return texts.string(b)
+	}
+	t.mu.Lock()
+	rt, ok := t.strs[string(b)]
+	if !ok {
+		rt = string(b)
+		t.strs[rt] = rt
+	}
+	t.mu.Unlock()
+	return rt
+}
+
+// text longer than this gets a string of its own
+const textSlabMax = 1 << 10
+
+// textSlab makes the strings of the text of a parse in a few large buffers
+// instead of one each. a buffer is only appended to, so the strings made in it
+// never change
+type textSlab []byte
+
+func (s *textSlab) string(b []byte) string {
+	if len(b) == 0 || len(b) > textSlabMax {
+		return string(b)
+	}
+	if cap(*s)-len(*s) < len(b) {
+		*s = make([]byte, 0, 4*textSlabMax)
+	}
+	i := len(*s)
+	*s = append(*s, b...)
+	return unsafe.String(&(*s)[i], len(b))
+}
+
+func isSpace(b []byte) bool {
+	for _, c := range b {
+		switch c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return false
+		}
+	}
+	return true
+}
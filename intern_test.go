@@ -0,0 +1,85 @@
+package gdom
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestSharedNameTable(t *testing.T) {
+	names := NewNameTable()
+	opts := &ParseOptions{Names: names}
+	d1, err := ParseWithOptions(strings.NewReader("<beans>\n  <bean id=\"a\"/>\n</beans>"), opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	d2, err := ParseWithOptions(strings.NewReader("<beans>\n  <bean id=\"b\"/>\n</beans>"), opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b1 := d1.Root().AllEles()[0]
+	b2 := d2.Root().AllEles()[0]
+	if unsafe.StringData(b1.Name.Local) != unsafe.StringData(b2.Name.Local) {
+		t.Error("element names not shared")
+	}
+	if unsafe.StringData(b1.attrs[0].Name.Local) != unsafe.StringData(b2.attrs[0].Name.Local) {
+		t.Error("attr names not shared")
+	}
+	ws1 := d1.Root().AllCharData()[0].V
+	ws2 := d2.Root().AllCharData()[0].V
+	if unsafe.StringData(ws1) != unsafe.StringData(ws2) {
+		t.Error("whitespace not shared")
+	}
+	if names.Len() != 5 {
+		t.Error("wrong table size", names.Len())
+	}
+}
+
+func TestLazyAttrMap(t *testing.T) {
+	e := NewEle(NewName("", "e"), nil)
+	for i := 0; i < attrMapMin; i++ {
+		e.SetAttr(NewAttr(NewName("", "a"+strconv.Itoa(i)), strconv.Itoa(i)))
+	}
+	if e.attrMap != nil {
+		t.Error("attr map made too early")
+	}
+	e.SetAttr(NewAttr(NewName("", "x"), "x"))
+	e.SetAttr(NewAttr(NewName("", "a1"), "one"))
+	if e.attrMap == nil {
+		t.Error("attr map not made")
+	}
+	if v, ok := e.GetAttrByStrName("", "a1"); !ok || v != "one" {
+		t.Error("wrong attr value", v)
+	}
+	if v, ok := e.RemoveAttrByStrName("", "x"); !ok || v != "x" {
+		t.Error("remove failed")
+	}
+	if _, ok := e.GetAttrByStrName("", "x"); ok || len(e.attrs) != attrMapMin {
+		t.Error("attr not removed")
+	}
+	// the attrs after a removed one move down
+	e.RemoveAttrByStrName("", "a1")
+	if v, ok := e.GetAttrByStrName("", "a7"); !ok || v != "7" || e.attrMap[NewName("", "a7")] != 6 {
+		t.Error("attr index not moved", v)
+	}
+	d := NewDoc(NewName("", "p"))
+	d.Root().AddEle(e)
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTextSlab(t *testing.T) {
+	d, _ := ParseString(`<p><a>one</a><a>two</a><a>` + strings.Repeat("x", textSlabMax+1) + `</a></p>`)
+	as := d.Root().AllEles()
+	one, two := as[0].Text(), as[1].Text()
+	if one != "one" || two != "two" || len(as[2].Text()) != textSlabMax+1 {
+		t.Error("wrong text", one, two)
+	}
+	if unsafe.StringData(two) != (*byte)(unsafe.Add(unsafe.Pointer(unsafe.StringData(one)), 3)) {
+		t.Error("text not made in one buffer")
+	}
+}
@@ -0,0 +1,117 @@
+// Command entities writes the HTML5 named character references of the html
+// package of the go tree as a table of package gdom, see ParseOptions.HTMLEntities
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	out := flag.String("o", "entity_html.go", "the file to write")
+	flag.Parse()
+	src := filepath.Join(runtime.GOROOT(), "src", "html", "entity.go")
+	ents, err := read(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := gen(ents)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, b, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// read returns the references of the maps entity and entity2 of the file src
+// that end with a ;, without it
+func read(src string) (map[string][]rune, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), src, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	ents := make(map[string][]rune)
+	ast.Inspect(f, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || len(as.Lhs) != 1 || len(as.Rhs) != 1 {
+			return true
+		}
+		id, ok := as.Lhs[0].(*ast.Ident)
+		lit, ok2 := as.Rhs[0].(*ast.CompositeLit)
+		if !ok || !ok2 || (id.Name != "entity" && id.Name != "entity2") {
+			return true
+		}
+		for _, el := range lit.Elts {
+			kv := el.(*ast.KeyValueExpr)
+			name, err := strconv.Unquote(kv.Key.(*ast.BasicLit).Value)
+			if err != nil || !strings.HasSuffix(name, ";") {
+				continue
+			}
+			var rs []rune
+			switch v := kv.Value.(type) {
+			case *ast.BasicLit:
+				rs = append(rs, char(v))
+			case *ast.CompositeLit:
+				for _, c := range v.Elts {
+					rs = append(rs, char(c.(*ast.BasicLit)))
+				}
+			}
+			ents[strings.TrimSuffix(name, ";")] = rs
+		}
+		return false
+	})
+	if len(ents) == 0 {
+		return nil, fmt.Errorf("no references found in %s", src)
+	}
+	// html leaves them out, they are longer than their names
+	ents["nGt"] = []rune{'\u226B', '\u20D2'}
+	ents["nLt"] = []rune{'\u226A', '\u20D2'}
+	return ents, nil
+}
+
+func char(lit *ast.BasicLit) rune {
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return []rune(s)[0]
+}
+
+func gen(ents map[string][]rune) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by internal/gen/entities from the HTML5 named character references of the html package. DO NOT EDIT.\n\n")
+	buf.WriteString("package gdom\n\nimport \"sync\"\n\n")
+	buf.WriteString("// html5Entities returns the HTML5 named character references, without the ;\n")
+	buf.WriteString("var html5Entities = sync.OnceValue(func() map[string]string {\n\treturn map[string]string{\n")
+	names := make([]string, 0, len(ents))
+	for name := range ents {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		var v strings.Builder
+		for _, r := range ents[name] {
+			if r > 0xFFFF {
+				fmt.Fprintf(&v, `\U%08X`, r)
+			} else {
+				fmt.Fprintf(&v, `\u%04X`, r)
+			}
+		}
+		fmt.Fprintf(&buf, "\t\t%q: \"%s\",\n", name, v.String())
+	}
+	buf.WriteString("\t}\n})\n")
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,115 @@
+package gdom
+
+import (
+	"iter"
+)
+
+// the sequences below read the next node before yielding the current one,
+// so the loop body may remove the current node, like in IterNode
+
+func children(p Iparent) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		iterNode(p, yield)
+	}
+}
+
+func descendants(p Iparent) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		walkDescendants(p, yield)
+	}
+}
+
+// walkDescendants yields the nodes below p in pre-order, the children of an
+// *Ele removed by yield are skipped
+func walkDescendants(p Iparent, yield func(Node) bool) bool {
+	l := p.getNodes()
+	for i := 0; i < l.Len(); {
+		n := (*l)[i]
+		nxt := l.at(i + 1)
+		if !yield(n) {
+			return false
+		}
+		e, ok := n.(*Ele)
+		if ok && e.parent == p && !walkDescendants(e, yield) {
+			return false
+		}
+		if nxt == nil || nxt.GetParent() != p {
+			return true
+		}
+		i = nxt.index()
+	}
+	return true
+}
+
+// OfKind keeps the nodes of seq having the type T, like OfKind[*Comment](e.Descendants())
+func OfKind[T Node](seq iter.Seq[Node]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := range seq {
+			t, ok := n.(T)
+			if ok && !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// Named keeps the elements of seq with the name
+func Named(seq iter.Seq[*Ele], name Name) iter.Seq[*Ele] {
+	return func(yield func(*Ele) bool) {
+		for e := range seq {
+			if e.Name == name && !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// the child nodes of e
+func (e *Ele) Children() iter.Seq[Node] {
+	return children(e)
+}
+
+// the nodes below e in pre-order
+func (e *Ele) Descendants() iter.Seq[Node] {
+	return descendants(e)
+}
+
+// the child *Ele of e
+func (e *Ele) ChildElements() iter.Seq[*Ele] {
+	return OfKind[*Ele](children(e))
+}
+
+// the child *Ele of e with the name
+func (e *Ele) ChildElementsByName(name Name) iter.Seq[*Ele] {
+	return Named(e.ChildElements(), name)
+}
+
+// the *Ele below e with the name, in pre-order
+func (e *Ele) DescendantsByName(name Name) iter.Seq[*Ele] {
+	return Named(OfKind[*Ele](descendants(e)), name)
+}
+
+// the attrs of e in order, the loop body may call e.RemoveAttr on the current attr
+func (e *Ele) Attrs() iter.Seq[*Attr] {
+	return func(yield func(*Attr) bool) {
+		for i := 0; i < len(e.attrs); {
+			a := e.attrs[i]
+			if !yield(a) {
+				return
+			}
+			if i < len(e.attrs) && e.attrs[i] == a {
+				i++
+			}
+		}
+	}
+}
+
+// the child nodes of the doc
+func (d *Doc) Children() iter.Seq[Node] {
+	return children(d)
+}
+
+// all the nodes of the doc in pre-order
+func (d *Doc) All() iter.Seq[Node] {
+	return descendants(d)
+}
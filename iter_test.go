@@ -0,0 +1,65 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	d, _ := ParseString(`<?pi x?><p a="1" b="2"><a><!--1--><b/></a>t<b><!--2--></b></p>`)
+	s := ""
+	for n := range d.All() {
+		switch nd := n.(type) {
+		case *Ele:
+			s += nd.Name.Local
+		case *CharData:
+			s += nd.V
+		case *Comment:
+			s += nd.V
+		case *ProcInst:
+			s += nd.Target
+		}
+	}
+	if s != "pipa1btb2" {
+		t.Error("all failed", s)
+	}
+	cnt := 0
+	for range OfKind[*Comment](d.Root().Descendants()) {
+		cnt++
+	}
+	if cnt != 2 {
+		t.Error("of kind failed")
+	}
+	cnt = 0
+	for range d.Root().DescendantsByName(NewName("", "b")) {
+		cnt++
+	}
+	if cnt != 2 {
+		t.Error("descendants by name failed")
+	}
+	for a := range d.Root().Attrs() {
+		if a.Name.Local == "a" {
+			d.Root().RemoveAttr(a)
+		}
+	}
+	if _, ok := d.Root().GetAttrByStrName("", "b"); !ok || len(d.Root().attrs) != 1 {
+		t.Error("remove attr in loop failed")
+	}
+}
+
+func TestIterRemove(t *testing.T) {
+	d, _ := ParseString(`<p><a><x/></a><b/><a/><c/></p>`)
+	for e := range d.Root().Descendants() {
+		if ee, ok := e.(*Ele); ok && ee.Name.Local == "a" {
+			RemoveSelf(e)
+		}
+	}
+	if d.ToString() != "<p><b/><c/></p>" {
+		t.Error("remove in loop failed", d.ToString())
+	}
+	for e := range d.Root().ChildElements() {
+		d.Root().RemoveNode(e)
+	}
+	if d.ToString() != "<p/>" {
+		t.Error("remove child elements in loop failed", d.ToString())
+	}
+}
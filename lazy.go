@@ -0,0 +1,352 @@
+package gdom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// lazySrc is the doc a lazy parse was made from, with the content span of
+// every element that is not empty
+type lazySrc struct {
+	b     []byte
+	spans []lazySpan
+	names *NameTable
+	// the named entities of the parse, with the ones of the DOCTYPE
+	entities map[string]string
+	keepRefs bool
+	lossless bool
+}
+
+// the content of an element is b[open:close], its end tag ends at end
+type lazySpan struct {
+	open, close, end int
+}
+
+// lazyContent holds the children of an element that are not materialized yet
+type lazyContent interface {
+	// add the children to e
+	load(e *Ele) error
+	// write the children as they are
+	write(w io.Writer) error
+}
+
+// lazyEle is the raw content of an element parsed by ParseLazy
+type lazyEle struct {
+	src  *lazySrc
+	span lazySpan
+	// the error the content was found broken with, it is not read again
+	err error
+}
+
+func (l *lazyEle) load(e *Ele) error {
+	if l.err == nil {
+		l.err = l.src.load(e, l.span.open, l.span.close)
+	}
+	return l.err
+}
+
+func (l *lazyEle) write(w io.Writer) error {
+	_, err := w.Write(l.src.b[l.span.open:l.span.close])
+	return err
+}
+
+// ParseLazy indexes the element boundaries of bts in one scan and keeps the content
+// of every element as raw bytes until its children are asked for, through IterNode,
+// Eles, AllNodes and the like. elements whose children were never materialized
+// are written as the original bytes. bts must not be changed while the doc is in use.
+// the scan only checks that the tags nest: an element whose content turns out
+// broken when it is materialized gets no children and keeps the raw bytes, so
+// that it is still written as it was. Materialize returns the error
+func ParseLazy(bts []byte) (*Doc, error) {
+	return ParseLazyWithOptions(bts, nil)
+}
+
+// ParseLazyWithOptions is ParseLazy with opts, Positions aside: the nodes get no positions
+func ParseLazyWithOptions(bts []byte, opts *ParseOptions) (*Doc, error) {
+	spans, err := scanSpans(bts)
+	if err != nil {
+		return nil, err
+	}
+	src := &lazySrc{b: bts, spans: spans}
+	if opts != nil {
+		src.names = opts.Names
+		src.entities = opts.entityTable()
+		src.keepRefs = opts.KeepEntityRefs
+		src.lossless = opts.Lossless
+	}
+	if src.names == nil {
+		src.names = NewNameTable()
+	}
+	d := &Doc{}
+	if opts != nil {
+		d.file = opts.FileName
+	}
+	err = src.load(d, 0, len(bts))
+	if err != nil {
+		return nil, err
+	}
+	if d.root == nil {
+		return nil, errors.New("no root element")
+	}
+	return d, nil
+}
+
+// load adds the nodes of b[from:to] to p, every child element that is not
+// empty gets its content as a lazyEle
+func (s *lazySrc) load(p Iparent, from, to int) error {
+	rd := bytes.NewReader(s.b[from:to])
+	// the decoder reads a bytes.Reader byte by byte, so the position of rd is
+	// the end of the last token and rd can be moved past a skipped element
+	decoder := xml.NewDecoder(rd)
+	decoder.Entity = s.entities
+	// the bytes skipped by moving rd, that the decoder does not count
+	skipped := 0
+	var texts textSlab
+	for {
+		at := from + skipped + int(decoder.InputOffset())
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		end := from + skipped + int(decoder.InputOffset())
+		switch t := token.(type) {
+		case xml.StartElement:
+			ele := NewEle(s.names.name(t.Name), nil)
+			if len(t.Attr) > 0 {
+				ele.attrs = make([]*Attr, 0, len(t.Attr))
+			}
+			for i := 0; i < len(t.Attr); i++ {
+				ele.SetAttr(NewAttr(s.names.name(t.Attr[i].Name), t.Attr[i].Value))
+			}
+			if s.lossless {
+				ele.raw = newRawTag(ele, s.b[at:end])
+			}
+			addEle(p, ele)
+			if d, ok := p.(*Doc); ok {
+				if d.root != nil {
+					return errors.New("wrong format, muilti root element")
+				}
+				d.root = ele
+			}
+			open := end
+			if s.b[open-2] == '/' {
+				// the decoder gives the EndElement of <a/> next
+				continue
+			}
+			i := sort.Search(len(s.spans), func(i int) bool {
+				return s.spans[i].open >= open
+			})
+			if i == len(s.spans) || s.spans[i].open != open {
+				return errors.New("lazy parse lost the end of " + nameString(ele.Name))
+			}
+			ele.lazy = &lazyEle{src: s, span: s.spans[i]}
+			if ele.raw != nil {
+				ele.raw.end = string(s.b[s.spans[i].close:s.spans[i].end])
+			}
+			rd.Seek(int64(s.spans[i].end-from), io.SeekStart)
+			skipped += s.spans[i].end - open
+		case xml.EndElement:
+		case xml.CharData:
+			if s.keepRefs && hasRefs(s.b[at:end], s.entities) {
+				addRefs(p, s.b[at:end], Position{}, s.entities, nil, s.lossless)
+				break
+			}
+			cd := NewCharData(s.names.text(t, &texts))
+			cd.IsCDATA = isCDATA(s.b[at:])
+			if s.lossless {
+				cd.raw = &rawText{b: string(s.b[at:end]), v: cd.V}
+			}
+			addCharData(p, cd)
+			if !attached(cd) {
+				// merged into the CharData before it
+				p.getNodes().Back().(*CharData).extend(s.b[at:end])
+			}
+		case xml.Comment:
+			addComment(p, NewComment(string(t)))
+		case xml.ProcInst:
+			pi := NewProcInst(t.Target, string(t.Inst))
+			if s.lossless {
+				pi.raw = &rawProcInst{b: string(s.b[at:end]), target: pi.Target, inst: pi.Inst}
+			}
+			addProcInst(p, pi)
+		case xml.Directive:
+			if d, ok := p.(*Doc); ok {
+				if dt, ok := parseDocType(string(t)); ok {
+					addDocType(d, dt)
+					s.entities, err = withDTD(s.entities, dt)
+					if err != nil {
+						return err
+					}
+					decoder.Entity = s.entities
+					break
+				}
+			}
+			addDirective(p, NewDirective(string(t)))
+		}
+	}
+}
+
+// load materializes the children of e if they are still raw bytes
+func (e *Ele) load() error {
+	l := e.lazy
+	if l == nil {
+		return nil
+	}
+	if le, ok := l.(*lazyEle); ok && le.err != nil {
+		// known broken, e is not touched so that a frozen doc is only read
+		return le.err
+	}
+	e.lazy = nil
+	n := e.nodes.Len()
+	// the children were there all along, a transaction must not record them
+	e.loading = true
+	err := l.load(e)
+	e.loading = false
+	if err != nil {
+		// the content stays raw, the children made before the error are dropped
+		for _, c := range e.nodes[n:] {
+			c.clearParent()
+			c.setIndex(-1)
+		}
+		e.nodes = e.nodes[:n]
+		e.lazy = l
+	}
+	return err
+}
+
+// Materialize makes the children of e and of its descendants that are still raw
+// bytes, see ParseLazy. it returns the first error found, the element the
+// error is in keeps its raw content
+func (e *Ele) Materialize() error {
+	err := e.load()
+	for _, n := range e.nodes {
+		if c, ok := n.(*Ele); ok {
+			if cerr := c.Materialize(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	return err
+}
+
+// Materialize makes all the children of d that are still raw bytes, see
+// Ele.Materialize
+func (d *Doc) Materialize() error {
+	var err error
+	for _, n := range d.nodes {
+		if e, ok := n.(*Ele); ok {
+			if eerr := e.Materialize(); err == nil {
+				err = eerr
+			}
+		}
+	}
+	return err
+}
+
+// scanSpans finds the content span of every element that is not empty, in the
+// order of their start tags. it only checks that the tags nest
+func scanSpans(b []byte) ([]lazySpan, error) {
+	spans := make([]lazySpan, 0, 64)
+	stack := make([]int, 0, 16)
+	for i := 0; i < len(b); {
+		j := bytes.IndexByte(b[i:], '<')
+		if j < 0 {
+			break
+		}
+		i += j
+		var k int
+		switch {
+		case bytes.HasPrefix(b[i:], []byte("<!--")):
+			k = indexAfter(b, i+4, "-->")
+		case bytes.HasPrefix(b[i:], []byte("<![CDATA[")):
+			k = indexAfter(b, i+9, "]]>")
+		case bytes.HasPrefix(b[i:], []byte("<?")):
+			k = indexAfter(b, i+2, "?>")
+		case bytes.HasPrefix(b[i:], []byte("<!")):
+			k = directiveEnd(b, i+2)
+		case bytes.HasPrefix(b[i:], []byte("</")):
+			k = indexAfter(b, i+2, ">")
+			if k > 0 {
+				if len(stack) == 0 {
+					return nil, errors.New("unexpected end tag at " + strconv.Itoa(i))
+				}
+				sp := &spans[stack[len(stack)-1]]
+				sp.close, sp.end = i, k
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			k = tagEnd(b, i+1)
+			if k > 0 && b[k-2] != '/' {
+				stack = append(stack, len(spans))
+				spans = append(spans, lazySpan{open: k})
+			}
+		}
+		if k <= 0 {
+			return nil, errors.New("unterminated markup at " + strconv.Itoa(i))
+		}
+		i = k
+	}
+	if len(stack) > 0 {
+		return nil, errors.New("unclosed element at " + strconv.Itoa(spans[stack[len(stack)-1]].open))
+	}
+	return spans, nil
+}
+
+// indexAfter returns the index after the first s in b from i on, -1 if there is none
+func indexAfter(b []byte, i int, s string) int {
+	j := bytes.Index(b[i:], []byte(s))
+	if j < 0 {
+		return -1
+	}
+	return i + j + len(s)
+}
+
+// tagEnd returns the index after the '>' closing the tag whose name starts at i
+func tagEnd(b []byte, i int) int {
+	var quote byte
+	for ; i < len(b); i++ {
+		switch {
+		case quote != 0:
+			if b[i] == quote {
+				quote = 0
+			}
+		case b[i] == '"' || b[i] == '\'':
+			quote = b[i]
+		case b[i] == '>':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// directiveEnd returns the index after the '>' closing a <!...> that may hold
+// nested markup, like a doctype with an internal subset
+func directiveEnd(b []byte, i int) int {
+	depth := 0
+	var quote byte
+	for ; i < len(b); i++ {
+		switch {
+		case quote != 0:
+			if b[i] == quote {
+				quote = 0
+			}
+		case b[i] == '"' || b[i] == '\'':
+			quote = b[i]
+		case b[i] == '<':
+			depth++
+		case b[i] == '>':
+			if depth == 0 {
+				return i + 1
+			}
+			depth--
+		}
+	}
+	return -1
+}
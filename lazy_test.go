@@ -0,0 +1,78 @@
+package gdom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseLazy(t *testing.T) {
+	xs := `<?xml version="1.0"?>
+<beans a='1'>
+  <bean id='a'><property name='x'   value="1"/><![CDATA[<raw>]]></bean>
+  <!-- <bean id="fake"> -->
+  <bean id="b"><list><value>&lt;1&gt;</value></list></bean>
+  <empty/>
+</beans>`
+	d, err := ParseLazy([]byte(xs))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d.Root().lazy == nil || d.Root().Name.Local != "beans" {
+		t.Error("root content not kept lazy")
+	}
+	if !strings.Contains(d.ToString(), `<bean id='a'><property name='x'   value="1"/><![CDATA[<raw>]]></bean>`) {
+		t.Error("untouched content not written verbatim", d.ToString())
+	}
+	bs := d.Root().ElesByStrName("", "bean")
+	if len(bs) != 2 || bs[0].lazy == nil || d.Root().lazy != nil {
+		t.Error("wrong materialized children", len(bs))
+		return
+	}
+	bs[1].ElesByStrName("", "list")[0].SetAttr(NewAttr(NewName("", "n"), "2"))
+	s := d.ToString()
+	if !strings.Contains(s, `<property name='x'   value="1"/>`) || !strings.Contains(s, `<list n="2"><value>&lt;1&gt;</value></list>`) {
+		t.Error("wrong write after a change", s)
+	}
+	full, _ := ParseString(xs)
+	full.Root().ElesByStrName("", "bean")[1].ElesByStrName("", "list")[0].SetAttr(NewAttr(NewName("", "n"), "2"))
+	for range d.All() {
+	}
+	if d.ToString() != full.ToString() {
+		t.Error("materialized doc differs from Parse", d.ToString())
+	}
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+	if _, err := ParseLazy([]byte(`<a><b></a>`)); err == nil {
+		t.Error("unclosed element not found")
+	}
+}
+
+func TestParseLazyBroken(t *testing.T) {
+	src := `<a><b>&nbsp;x</b><c>y</c></a>`
+	d, err := ParseLazy([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := d.Root().AllEles()[0]
+	if b.Text() != "" || len(b.AllNodes()) != 0 {
+		t.Error("children of broken content:", b.AllNodes())
+	}
+	if d.ToString() != src {
+		t.Error("broken content not kept:", d.ToString())
+	}
+	if err := d.Materialize(); err == nil {
+		t.Error("no error for the broken content")
+	}
+	if d.Root().AllEles()[1].Text() != "y" || d.ToString() != src {
+		t.Error("materialize:", d.ToString())
+	}
+	b.AddEle(NewEle(NewName("", "n"), nil))
+	if len(b.AllNodes()) != 1 || d.ToString() != `<a><b>&nbsp;x<n/></b><c>y</c></a>` {
+		t.Error("add to broken content:", d.ToString())
+	}
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,76 @@
+package gdom
+
+// Link stands in for the *list.Element every node embedded in v1, so that v1
+// code walking the siblings like n.Next().Value.(*Ele) keeps compiling. it is
+// meant for the transition only, see MIGRATION.md
+type Link struct {
+	// the node, an any like the Value of a *list.Element
+	Value any
+}
+
+func linkOf(n Node) *Link {
+	if n == nil {
+		return nil
+	}
+	return &Link{Value: n}
+}
+
+// Next returns the Link of the next sibling, nil if there is none
+func (l *Link) Next() *Link {
+	return linkOf(nextSibling(l.Value.(Node)))
+}
+
+// Prev returns the Link of the previous sibling, nil if there is none
+func (l *Link) Prev() *Link {
+	return linkOf(prevSibling(l.Value.(Node)))
+}
+
+// Deprecated: use NextSibling
+func (e *Ele) Next() *Link {
+	return linkOf(nextSibling(e))
+}
+
+// Deprecated: use PrevSibling
+func (e *Ele) Prev() *Link {
+	return linkOf(prevSibling(e))
+}
+
+// Deprecated: use NextSibling
+func (c *CharData) Next() *Link {
+	return linkOf(nextSibling(c))
+}
+
+// Deprecated: use PrevSibling
+func (c *CharData) Prev() *Link {
+	return linkOf(prevSibling(c))
+}
+
+// Deprecated: use NextSibling
+func (c *Comment) Next() *Link {
+	return linkOf(nextSibling(c))
+}
+
+// Deprecated: use PrevSibling
+func (c *Comment) Prev() *Link {
+	return linkOf(prevSibling(c))
+}
+
+// Deprecated: use NextSibling
+func (d *Directive) Next() *Link {
+	return linkOf(nextSibling(d))
+}
+
+// Deprecated: use PrevSibling
+func (d *Directive) Prev() *Link {
+	return linkOf(prevSibling(d))
+}
+
+// Deprecated: use NextSibling
+func (p *ProcInst) Next() *Link {
+	return linkOf(nextSibling(p))
+}
+
+// Deprecated: use PrevSibling
+func (p *ProcInst) Prev() *Link {
+	return linkOf(prevSibling(p))
+}
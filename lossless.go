@@ -0,0 +1,109 @@
+package gdom
+
+import "io"
+
+// rawTag is the source of the tags of an element parsed with
+// ParseOptions.Lossless, with the name and the attrs they were parsed to
+type rawTag struct {
+	start string
+	// empty for <a/>
+	end   string
+	name  Name
+	attrs []Attr
+}
+
+func newRawTag(e *Ele, start []byte) *rawTag {
+	r := &rawTag{
+		start: string(start),
+		name:  e.Name,
+		attrs: make([]Attr, 0, len(e.attrs)),
+	}
+	for _, a := range e.attrs {
+		r.attrs = append(r.attrs, Attr{Name: a.Name, Value: a.Value})
+	}
+	return r
+}
+
+// valid reports whether the tags still say what e is, the attrs aside
+func (r *rawTag) valid(e *Ele) bool {
+	if r.name != e.Name {
+		return false
+	}
+	if r.end == "" {
+		return e.nodes.Len() == 0 && e.lazy == nil
+	}
+	return true
+}
+
+// sameAttrs reports whether e still has the attrs the start tag was parsed to
+func (r *rawTag) sameAttrs(e *Ele) bool {
+	if len(r.attrs) != len(e.attrs) {
+		return false
+	}
+	for i, a := range e.attrs {
+		if a.Name != r.attrs[i].Name || a.Value != r.attrs[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Ele) writeRaw(w io.Writer) error {
+	var err error
+	if e.raw.sameAttrs(e) {
+		_, err = io.WriteString(w, e.raw.start)
+	} else {
+		// the attrs that were not changed keep their source, like WriteMinimal
+		tag := []byte(e.raw.start)
+		_, attrs := scanTag(tag)
+		m := &minimalWriter{src: tag, w: w}
+		m.startTag(e, 0, tag, attrs)
+		err = m.err
+	}
+	if err != nil || e.raw.end == "" {
+		return err
+	}
+	if e.lazy != nil {
+		err = e.lazy.write(w)
+		if err != nil {
+			return err
+		}
+	}
+	for _, n := range e.nodes {
+		err = n.Write(w)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(w, e.raw.end)
+	return err
+}
+
+// rawText is the source of a CharData parsed with ParseOptions.Lossless, with
+// the text it was parsed to: entity references and CDATA sections as they were
+type rawText struct {
+	b string
+	v string
+}
+
+// extend adds the source b of the CharData merged into c by the parse
+func (c *CharData) extend(b []byte) {
+	if c.raw != nil {
+		c.raw = &rawText{b: c.raw.b + string(b), v: c.V}
+	}
+}
+
+// rawProcInst is the source of a ProcInst parsed with ParseOptions.Lossless
+type rawProcInst struct {
+	b      string
+	target string
+	inst   string
+}
+
+func (p *ProcInst) writeRaw(w io.Writer) (bool, error) {
+	if p.raw == nil || p.raw.target != p.Target || p.raw.inst != p.Inst {
+		return false, nil
+	}
+	_, err := io.WriteString(w, p.raw.b)
+	return true, err
+}
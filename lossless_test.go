@@ -0,0 +1,59 @@
+package gdom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLossless(t *testing.T) {
+	src := "<?xml version='1.0'  encoding=\"UTF-8\"?>\n<beans  xmlns='urn:b'>\n" +
+		"  <bean id='a' class = \"A\"/>\n" +
+		"  <bean id=\"b\" title=\"&quot;x&quot;\"\n        class='B'>a &amp; b &#34;<![CDATA[<raw>]]></bean >\n</beans>"
+	d, err := ParseWithOptions(strings.NewReader(src), &ParseOptions{Lossless: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ToString() != src {
+		t.Error("not lossless:", d.ToString())
+	}
+	plain, _ := ParseString(src)
+	if plain.ToString() == src {
+		t.Error("plain parse kept the source")
+	}
+	beans := d.Root().AllEles()
+	beans[0].SetAttr(NewAttr(NewName("", "id"), "c"))
+	beans[1].AddEle(NewEle(NewName("", "property"), nil))
+	want := "<?xml version='1.0'  encoding=\"UTF-8\"?>\n<beans  xmlns='urn:b'>\n" +
+		"  <bean id='c' class = \"A\"/>\n" +
+		"  <bean id=\"b\" title=\"&quot;x&quot;\"\n        class='B'>a &amp; b &#34;<![CDATA[<raw>]]><property/></bean >\n</beans>"
+	if d.ToString() != want {
+		t.Error("edited:", d.ToString())
+	}
+	lazy, err := ParseLazyWithOptions([]byte(src), &ParseOptions{Lossless: true, FileName: "beans.xml"})
+	if err != nil || lazy.Materialize() != nil || lazy.ToString() != src {
+		t.Fatal("lazy not lossless:", err, lazy.ToString())
+	}
+	lbeans := lazy.Root().AllEles()
+	lbeans[0].SetAttr(NewAttr(NewName("", "id"), "c"))
+	lbeans[1].AddEle(NewEle(NewName("", "property"), nil))
+	if lazy.ToString() != want || lazy.FileName() != "beans.xml" {
+		t.Error("lazy edited:", lazy.ToString())
+	}
+	// text changed through the V field is written again
+	beans[1].FirstChild().(*CharData).V = "x"
+	if !strings.Contains(d.ToString(), "'B'>x<![CDATA[<raw>]]><property/>") {
+		t.Error("text:", d.ToString())
+	}
+	// an empty element that gets children needs an end tag
+	a := beans[0]
+	a.AddCharDataStr("t")
+	if !strings.Contains(d.ToString(), `class="A">t</bean>`) {
+		t.Error("empty:", d.ToString())
+	}
+
+	// the attrs that are not changed keep their source
+	beans[1].SetAttr(NewAttr(NewName("", "class"), `<"&>`))
+	if !strings.Contains(d.ToString(), `<bean id="b" title="&quot;x&quot;"`+"\n        "+`class='&lt;&#34;&amp;&gt;'>`) {
+		t.Error("attr changed:", d.ToString())
+	}
+}
@@ -0,0 +1,271 @@
+package gdom
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MergeOptions controls Merge3, nil means the zero value
+type MergeOptions struct {
+	// Keys are attributes used to match sibling elements, like "bean@id" or "*@id"
+	Keys []string
+	// add a Comment in front of each conflicting node of the result
+	ConflictComments bool
+}
+
+// Conflict is a change made by both ours and theirs that Merge3 could not combine.
+// the values are the attr value, the text or the xml of the node, empty if absent
+type Conflict struct {
+	Path string
+	// the attr name for attribute conflicts
+	Attr   Name
+	Reason string
+	Base   string
+	Ours   string
+	Theirs string
+}
+
+func (c Conflict) String() string {
+	p := c.Path
+	if c.Attr != (Name{}) {
+		p = p + "/@" + nameString(c.Attr)
+	}
+	return fmt.Sprintf("%s: %s (base %q, ours %q, theirs %q)", p, c.Reason, c.Base, c.Ours, c.Theirs)
+}
+
+// Merge3 merges the changes from base to theirs into a copy of ours.
+// elements are matched by Keys or by name and position, attributes by name.
+// nodes other than elements are kept as ours has them, so are the sibling order,
+// the comments and the formatting. on a conflict the result keeps ours
+func Merge3(base, ours, theirs *Doc, opts *MergeOptions) (*Doc, []Conflict) {
+	m := &merger{}
+	if opts != nil {
+		m.opts = *opts
+	}
+	m.keys = parseKeys(m.opts.Keys)
+	rt := ours.Copy()
+	r, b, t := rt.Root(), base.Root(), theirs.Root()
+	if r == nil || b == nil || t == nil {
+		if r != nil || b != nil || t != nil {
+			// there is nothing to match the elements of the others with
+			m.conflicts = append(m.conflicts, Conflict{Path: "/", Reason: "root element missing", Base: rootName(b), Ours: rootName(r), Theirs: rootName(t)})
+		}
+		return rt, m.conflicts
+	}
+	switch {
+	case r.Name == t.Name || t.Name == b.Name:
+		// renamed by neither, by both alike or by ours only
+	case r.Name == b.Name:
+		r.Name = t.Name
+	default:
+		m.conflict(rt, r, Conflict{Path: rootPath(m.keys, r), Reason: "root element renamed by ours and theirs", Base: rootName(b), Ours: rootName(r), Theirs: rootName(t)})
+		return rt, m.conflicts
+	}
+	m.mergeEle(rt, r, b, t, rootPath(m.keys, r))
+	return rt, m.conflicts
+}
+
+// rootName returns the name of the root element e, empty if there is none
+func rootName(e *Ele) string {
+	if e == nil {
+		return ""
+	}
+	return nameString(e.Name)
+}
+
+type merger struct {
+	opts      MergeOptions
+	keys      []keyRule
+	conflicts []Conflict
+}
+
+func (m *merger) conflict(p Iparent, at Node, c Conflict) {
+	m.conflicts = append(m.conflicts, c)
+	if !m.opts.ConflictComments || at == nil {
+		return
+	}
+	insertBefore(p, conflictComment(c), at)
+}
+
+// conflictGone is conflict for an element of base that ours removed, the
+// comment goes where insertLike would put the element back
+func (m *merger) conflictGone(r *Ele, rs map[string]*Ele, before []string, c Conflict) {
+	m.conflicts = append(m.conflicts, c)
+	if !m.opts.ConflictComments {
+		return
+	}
+	if anchor := anchorIn(r, rs, before); anchor != nil {
+		insertAfter(r, conflictComment(c), anchor)
+	} else if first := r.AllEles(); len(first) > 0 {
+		insertBefore(r, conflictComment(c), first[0])
+	} else {
+		r.AddComment(conflictComment(c))
+	}
+}
+
+func conflictComment(c Conflict) *Comment {
+	return NewComment(strings.Replace(" CONFLICT "+c.String()+" ", "--", "- -", -1))
+}
+
+// same reports whether the element subtrees a and b are equal apart from whitespace and comments
+func (m *merger) same(a, b *Ele) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	opts := &DiffOptions{Keys: m.opts.Keys, IgnoreWhitespace: true, IgnoreComments: true, IgnoreAttrOrder: true}
+	return len(DiffEle(a, b, opts)) == 0
+}
+
+// keyedEles returns the child elements of e by their matching key, and the keys in order
+func (m *merger) keyedEles(e *Ele) (map[string]*Ele, []string) {
+	rt := make(map[string]*Ele)
+	order := make([]string, 0, e.nodes.Len())
+	count := make(map[string]int)
+	for _, c := range e.AllEles() {
+		_, key := eleStep(m.keys, c, count)
+		rt[key] = c
+		order = append(order, key)
+	}
+	return rt, order
+}
+
+// mergeEle merges into r the changes from b to t, rp is the parent of r
+func (m *merger) mergeEle(rp Iparent, r, b, t *Ele, path string) {
+	m.mergeAttrs(rp, r, b, t, path)
+	m.mergeText(rp, r, b, t, path)
+	rs, rorder := m.keyedEles(r)
+	bs, _ := m.keyedEles(b)
+	ts, torder := m.keyedEles(t)
+	for _, k := range rorder {
+		re := rs[k]
+		be, inB := bs[k]
+		te, inT := ts[k]
+		p := path + "/" + k[2:]
+		switch {
+		case inB && inT:
+			m.mergeEle(r, re, be, te, p)
+		case inB:
+			if m.same(re, be) {
+				removeWhitespace(r, prevSibling(re))
+				removeNode(r, re)
+			} else {
+				m.conflict(r, re, Conflict{Path: p, Reason: "changed by ours, removed by theirs", Base: nodeString(be), Ours: nodeString(re)})
+			}
+		case inT:
+			if !m.same(re, te) {
+				m.conflict(r, re, Conflict{Path: p, Reason: "added differently by ours and theirs", Ours: nodeString(re), Theirs: nodeString(te)})
+			}
+		}
+	}
+	for i, k := range torder {
+		if _, ok := rs[k]; ok {
+			continue
+		}
+		te := ts[k]
+		be, inB := bs[k]
+		p := path + "/" + k[2:]
+		if inB {
+			if !m.same(be, te) {
+				m.conflictGone(r, rs, torder[:i], Conflict{Path: p, Reason: "removed by ours, changed by theirs", Base: nodeString(be), Theirs: nodeString(te)})
+			}
+			continue
+		}
+		rs[k] = insertLike(r, rs, torder[:i], te, leadingSpace(te))
+	}
+}
+
+// leadingSpace returns the whitespace only CharData in front of n, or nil
+func leadingSpace(n Node) *CharData {
+	cd, ok := prevSibling(n).(*CharData)
+	if ok && strings.TrimSpace(cd.V) == "" {
+		return cd
+	}
+	return nil
+}
+
+// anchorIn returns the element of rs keyed by the last of before that is still in r
+func anchorIn(r *Ele, rs map[string]*Ele, before []string) *Ele {
+	for i := len(before) - 1; i >= 0; i-- {
+		e, ok := rs[before[i]]
+		if ok && e.parent == Iparent(r) {
+			return e
+		}
+	}
+	return nil
+}
+
+// insertLike adds a copy of te and ws in front of it to r, after the element
+// of rs keyed by the last of before that is still in r. it returns the copy
+func insertLike(r *Ele, rs map[string]*Ele, before []string, te *Ele, ws *CharData) *Ele {
+	anchor := anchorIn(r, rs, before)
+	if anchor != nil {
+		insertAfter(r, te, anchor)
+		rt := nextSibling(anchor).(*Ele)
+		if ws != nil {
+			insertAfter(r, ws, anchor)
+		}
+		return rt
+	}
+	first := r.AllEles()
+	if len(first) > 0 {
+		if ws != nil {
+			insertBefore(r, ws, first[0])
+		}
+		insertBefore(r, te, first[0])
+		return prevSibling(first[0]).(*Ele)
+	}
+	if ws != nil {
+		r.AddCharData(ws)
+	}
+	r.AddEle(te)
+	return r.nodes.Back().(*Ele)
+}
+
+func (m *merger) mergeAttrs(rp Iparent, r, b, t *Ele, path string) {
+	names := make([]Name, 0, len(r.attrs)+len(t.attrs))
+	seen := make(map[Name]bool)
+	for _, e := range []*Ele{r, b, t} {
+		for _, a := range e.allAttrs() {
+			if !seen[a.Name] {
+				seen[a.Name] = true
+				names = append(names, a.Name)
+			}
+		}
+	}
+	for _, n := range names {
+		bv, inB := b.GetAttr(n)
+		rv, inR := r.GetAttr(n)
+		tv, inT := t.GetAttr(n)
+		if inB == inT && bv == tv {
+			continue
+		}
+		if inR == inT && rv == tv {
+			continue
+		}
+		if inR == inB && rv == bv {
+			if inT {
+				r.SetAttr(NewAttr(n, tv))
+			} else {
+				r.RemoveAttrByName(n)
+			}
+			continue
+		}
+		m.conflict(rp, r, Conflict{Path: path, Attr: n, Reason: "attribute changed by ours and theirs", Base: bv, Ours: rv, Theirs: tv})
+	}
+}
+
+// mergeText merges the text of elements without child elements
+func (m *merger) mergeText(rp Iparent, r, b, t *Ele, path string) {
+	if len(r.AllEles()) > 0 || len(t.AllEles()) > 0 {
+		return
+	}
+	bv, rv, tv := b.TrimedText(), r.TrimedText(), t.TrimedText()
+	if bv == tv || rv == tv {
+		return
+	}
+	if rv != bv {
+		m.conflict(rp, r, Conflict{Path: path, Reason: "text changed by ours and theirs", Base: bv, Ours: rv, Theirs: tv})
+		return
+	}
+	replaceText(r, t)
+}
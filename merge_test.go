@@ -0,0 +1,90 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base, _ := ParseString(`<beans>
+    <bean id="a" class="A"/>
+    <bean id="b" class="B"/>
+    <bean id="c" class="C"/>
+</beans>`)
+	ours, _ := ParseString(`<beans>
+    <!-- customer settings -->
+    <bean id="a" class="A" scope="prototype"/>
+    <bean id="b" class="B1"/>
+    <bean id="c" class="C"/>
+</beans>`)
+	theirs, _ := ParseString(`<beans>
+    <bean id="a" class="A2"/>
+    <bean id="b" class="B2"/>
+    <bean id="d" class="D"/>
+</beans>`)
+	rt, cs := Merge3(base, ours, theirs, &MergeOptions{Keys: []string{"bean@id"}})
+	rs := `<beans>
+    <!-- customer settings -->
+    <bean id="a" class="A2" scope="prototype"/>
+    <bean id="b" class="B1"/>
+    <bean id="d" class="D"/>
+</beans>`
+	if rt.ToString() != rs {
+		t.Error("merge failed")
+		t.Error(rt.ToString())
+	}
+	if len(cs) != 1 || cs[0].Path != "/beans[1]/bean[@id='b']" || cs[0].Theirs != "B2" {
+		t.Error("wrong conflicts", cs)
+	}
+	// the paths are those of Diff
+	found := false
+	for _, c := range Diff(base, theirs, &DiffOptions{Keys: []string{"bean@id"}}) {
+		found = found || c.Path == cs[0].Path
+	}
+	if !found {
+		t.Error("conflict path not in the diff:", cs[0].Path)
+	}
+}
+
+func TestMerge3ConflictComments(t *testing.T) {
+	base, _ := ParseString(`<p><a>1</a></p>`)
+	ours, _ := ParseString(`<p><a>2</a></p>`)
+	theirs, _ := ParseString(`<p><a>3</a></p>`)
+	rt, cs := Merge3(base, ours, theirs, &MergeOptions{ConflictComments: true})
+	if len(cs) != 1 || len(rt.Root().AllComments()) != 1 {
+		t.Error("conflict comment missing")
+		t.Error(rt.ToString())
+	}
+}
+
+func TestMerge3Root(t *testing.T) {
+	base, _ := ParseString(`<r><a>1</a></r>`)
+	theirs, _ := ParseString(`<s><a>2</a></s>`)
+	rt, cs := Merge3(base, base, theirs, nil)
+	if len(cs) != 0 || rt.ToString() != `<s><a>2</a></s>` {
+		t.Error("root renamed by theirs:", cs, rt.ToString())
+	}
+	ours, _ := ParseString(`<q><a>1</a></q>`)
+	rt, cs = Merge3(base, ours, theirs, nil)
+	if len(cs) != 1 || rt.ToString() != `<q><a>1</a></q>` {
+		t.Error("root renamed by both:", cs, rt.ToString())
+	}
+	rt, cs = Merge3(base, ours, base, nil)
+	if len(cs) != 0 || rt.ToString() != `<q><a>1</a></q>` {
+		t.Error("root renamed by ours:", cs, rt.ToString())
+	}
+	empty, _ := ParseString(`<!--no root-->`)
+	rt, cs = Merge3(base, ours, empty, nil)
+	if len(cs) != 1 || cs[0].Theirs != "" || rt.ToString() != `<q><a>1</a></q>` {
+		t.Error("no root:", cs, rt.ToString())
+	}
+}
+
+func TestMerge3RemovedConflict(t *testing.T) {
+	base, _ := ParseString(`<r><a>1</a></r>`)
+	ours, _ := ParseString(`<r/>`)
+	theirs, _ := ParseString(`<r><a>2</a></r>`)
+	rt, cs := Merge3(base, ours, theirs, &MergeOptions{ConflictComments: true})
+	if len(cs) != 1 || len(rt.Root().AllComments()) != 1 {
+		t.Error("conflict comment missing:", rt.ToString())
+	}
+}
@@ -0,0 +1,344 @@
+package gdom
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WriteMinimal writes d as a change of original, the source d was parsed from:
+// the bytes of every node that is still what it was parsed from are copied from
+// original, formatting, quotes, entity references and CDATA sections included.
+// a changed attr is written in place with the quote it had, a removed attr is
+// cut out and an added one is put after the last attr of the tag. changed text
+// and nodes that are added, moved or not made by Parse are written like Write does.
+// d must be parsed with ParseOptions.Positions, without them it is written whole
+func (d *Doc) WriteMinimal(original []byte, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	m := &minimalWriter{src: original, w: bw}
+	m.content(d, 0, len(original))
+	if m.err != nil {
+		return m.err
+	}
+	return bw.Flush()
+}
+
+type minimalWriter struct {
+	src []byte
+	w   io.Writer
+	err error
+}
+
+func (m *minimalWriter) copy(from, to int) {
+	if m.err == nil && from < to {
+		_, m.err = m.w.Write(m.src[from:to])
+	}
+}
+
+func (m *minimalWriter) str(s string) {
+	if m.err == nil {
+		_, m.err = io.WriteString(m.w, s)
+	}
+}
+
+func (m *minimalWriter) node(n Node) {
+	if m.err == nil {
+		m.err = n.Write(m.w)
+	}
+}
+
+// content writes the children of p, that were src[from:to]. the longest run of
+// children whose spans are still in order is copied, the bytes between their
+// spans were children that are gone
+func (m *minimalWriter) content(p Iparent, from, to int) {
+	nodes := *p.getNodes()
+	kept := inOrder(nodes, from, to)
+	cur := from
+	for i, n := range nodes {
+		sp := n.Pos()
+		if kept[i] && sp.Start.Offset >= cur {
+			m.same(n, sp.Start.Offset, sp.End.Offset)
+			cur = sp.End.Offset
+		} else {
+			m.node(n)
+		}
+	}
+}
+
+// inOrder marks the longest run of nodes whose spans are in src[from:to] and
+// start one after the other
+func inOrder(nodes nodeList, from, to int) []bool {
+	start := func(i int) int {
+		return nodes[i].Pos().Start.Offset
+	}
+	// tails[k] is the node ending the best run of k+1 nodes found so far
+	tails := make([]int, 0, len(nodes))
+	prev := make([]int, len(nodes))
+	for i, n := range nodes {
+		sp := n.Pos()
+		if !sp.Start.IsValid() || sp.Start.Offset < from || sp.End.Offset > to {
+			continue
+		}
+		k := sort.Search(len(tails), func(k int) bool {
+			return start(tails[k]) >= sp.Start.Offset
+		})
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	kept := make([]bool, len(nodes))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			kept[i] = true
+		}
+	}
+	return kept
+}
+
+// same writes n, that was parsed from src[from:to]
+func (m *minimalWriter) same(n Node, from, to int) {
+	switch t := n.(type) {
+	case *Ele:
+		m.ele(t, from, to)
+	case *CharData:
+		if textOf(m.src[from:to]) == t.V {
+			m.copy(from, to)
+		} else {
+			m.node(n)
+		}
+	case *ProcInst:
+		target, inst := procInstOf(m.src[from:to])
+		if target == t.Target && inst == t.Inst {
+			m.copy(from, to)
+		} else {
+			m.node(n)
+		}
+	default:
+		// comments and directives are written as they were parsed
+		m.node(n)
+	}
+}
+
+func (m *minimalWriter) ele(e *Ele, from, to int) {
+	end := tagEnd(m.src, from+1)
+	if e.lazy != nil || end < 0 || end > to {
+		m.node(e)
+		return
+	}
+	tag := m.src[from:end]
+	name, attrs := scanTag(tag)
+	empty := bytes.HasSuffix(tag, []byte("/>"))
+	if name != nameString(e.Name) || (empty && e.getNodes().Len() > 0) {
+		m.node(e)
+		return
+	}
+	m.startTag(e, from, tag, attrs)
+	if empty {
+		return
+	}
+	endTag := bytes.LastIndexByte(m.src[:to], '<')
+	if endTag < end {
+		m.node(e)
+		return
+	}
+	m.content(e, end, endTag)
+	m.copy(endTag, to)
+}
+
+// startTag writes the start tag of e, that was src[from:from+len(tag)]
+func (m *minimalWriter) startTag(e *Ele, from int, tag []byte, attrs []tagAttr) {
+	cur := from
+	// the added attrs go after the last attr of the tag
+	last := from + len(tag) - 1
+	if bytes.HasSuffix(tag, []byte("/>")) {
+		last--
+	}
+	last = from + bytes.LastIndexFunc(tag[:last-from], func(r rune) bool {
+		return !isTagSpace(byte(r))
+	}) + 1
+	for _, a := range attrs {
+		v, ok := e.GetAttr(a.name)
+		switch {
+		case !ok:
+			m.copy(cur, from+a.ws)
+			cur = from + a.to
+		case v != a.value:
+			m.copy(cur, from+a.val)
+			m.escape(v)
+			m.str(string(tag[a.to-1]))
+			cur = from + a.to
+		}
+	}
+	m.copy(cur, last)
+	for _, a := range e.attrs {
+		if !hasTagAttr(attrs, a.Name) {
+			m.str(" " + nameString(a.Name) + `="`)
+			m.escape(a.Value)
+			m.str(`"`)
+		}
+	}
+	m.copy(last, from+len(tag))
+}
+
+func (m *minimalWriter) escape(v string) {
+	if m.err == nil {
+		m.err = EscapeWithoutSpace(m.w, []byte(v))
+	}
+}
+
+// tagAttr is an attr of a start tag, tag[ws:to] is the attr with the space
+// before it, its value starts at val
+type tagAttr struct {
+	name  Name
+	value string
+	ws    int
+	val   int
+	to    int
+}
+
+func hasTagAttr(attrs []tagAttr, name Name) bool {
+	for _, a := range attrs {
+		if a.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// scanTag returns the name and the attrs of the start tag tag
+func scanTag(tag []byte) (string, []tagAttr) {
+	i := 1
+	for i < len(tag) && !isTagSpace(tag[i]) && tag[i] != '/' && tag[i] != '>' {
+		i++
+	}
+	name := string(tag[1:i])
+	var attrs []tagAttr
+	for {
+		a := tagAttr{ws: i}
+		for i < len(tag) && isTagSpace(tag[i]) {
+			i++
+		}
+		if i >= len(tag) || tag[i] == '/' || tag[i] == '>' {
+			return name, attrs
+		}
+		n := i
+		for i < len(tag) && tag[i] != '=' && !isTagSpace(tag[i]) {
+			i++
+		}
+		a.name = splitName(string(tag[n:i]))
+		for i < len(tag) && tag[i] != '"' && tag[i] != '\'' {
+			i++
+		}
+		if i >= len(tag) {
+			return name, attrs
+		}
+		a.val = i + 1
+		j := bytes.IndexByte(tag[a.val:], tag[i])
+		if j < 0 {
+			return name, attrs
+		}
+		a.to = a.val + j + 1
+		a.value = textOf(tag[a.val : a.to-1])
+		attrs = append(attrs, a)
+		i = a.to
+	}
+}
+
+// splitName makes the Name of a prefixed name like RawToken does
+func splitName(s string) Name {
+	space, local, ok := strings.Cut(s, ":")
+	if !ok {
+		return Name{Local: s}
+	}
+	return Name{Space: space, Local: local}
+}
+
+// textOf returns the text the decoder makes of the source b: entity references
+// are replaced, CDATA sections unwrapped and line ends made \n
+func textOf(b []byte) string {
+	if bytes.IndexAny(b, "&<\r") < 0 {
+		return string(b)
+	}
+	var sb strings.Builder
+	for i := 0; i < len(b); {
+		switch {
+		case bytes.HasPrefix(b[i:], []byte("<![CDATA[")):
+			k := indexAfter(b, i+9, "]]>")
+			if k < 0 {
+				k = len(b) + 3
+			}
+			sb.WriteString(textOf(b[i+9 : k-3]))
+			i = k
+		case b[i] == '&':
+			j := bytes.IndexByte(b[i:], ';')
+			if j < 0 {
+				sb.Write(b[i:])
+				return sb.String()
+			}
+			sb.WriteString(entityOf(string(b[i+1 : i+j])))
+			i += j + 1
+		case b[i] == '\r':
+			sb.WriteByte('\n')
+			i++
+			if i < len(b) && b[i] == '\n' {
+				i++
+			}
+		default:
+			sb.WriteByte(b[i])
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// entityOf returns the text of the entity reference &name;
+func entityOf(name string) string {
+	switch name {
+	case "lt":
+		return "<"
+	case "gt":
+		return ">"
+	case "amp":
+		return "&"
+	case "apos":
+		return "'"
+	case "quot":
+		return `"`
+	}
+	if strings.HasPrefix(name, "#") {
+		var r uint64
+		var err error
+		if strings.HasPrefix(name, "#x") {
+			r, err = strconv.ParseUint(name[2:], 16, 32)
+		} else {
+			r, err = strconv.ParseUint(name[1:], 10, 32)
+		}
+		if err == nil && utf8.ValidRune(rune(r)) {
+			return string(rune(r))
+		}
+	}
+	// never what the decoder makes, the node is written again
+	return "&" + name + ";"
+}
+
+// procInstOf returns the target and the inst of the source b of a ProcInst
+func procInstOf(b []byte) (string, string) {
+	b = bytes.TrimSuffix(bytes.TrimPrefix(b, []byte("<?")), []byte("?>"))
+	i := bytes.IndexFunc(b, func(r rune) bool {
+		return isTagSpace(byte(r))
+	})
+	if i < 0 {
+		return string(b), ""
+	}
+	return string(b[:i]), string(bytes.TrimLeft(b[i:], " \t\r\n"))
+}
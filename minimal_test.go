@@ -0,0 +1,52 @@
+package gdom
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteMinimal(t *testing.T) {
+	src := "<?xml version='1.0'?>\n<beans  xmlns:p='urn:p'>\n" +
+		"  <bean id='a'   class = \"A\" p:x=\"&quot;1&quot;\"/>\n" +
+		"  <bean id=\"b\"\n        class='B'>a &amp; b<![CDATA[<raw>]]></bean >\n" +
+		"  <!-- gone --><bean id='c'><v>1</v></bean>\n</beans>\n"
+	d, err := ParseWithOptions(strings.NewReader(src), &ParseOptions{Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	d.WriteMinimal([]byte(src), &buf)
+	if buf.String() != src {
+		t.Error("untouched:", buf.String())
+	}
+	beans := d.Root().ElesByStrName("", "bean")
+	beans[0].SetAttr(NewAttr(NewName("", "class"), "A2"))
+	beans[0].RemoveAttrByStrName("", "id")
+	beans[0].SetAttr(NewAttr(NewName("", "scope"), "x<y"))
+	c := beans[2]
+	c.FirstChild().FirstChild().(*CharData).V = "2"
+	RemoveSelf(c.PrevSibling())
+	buf.Reset()
+	d.WriteMinimal([]byte(src), &buf)
+	want := strings.NewReplacer(
+		"<bean id='a'   class = \"A\" p:x=\"&quot;1&quot;\"/>", "<bean   class = \"A2\" p:x=\"&quot;1&quot;\" scope=\"x&lt;y\"/>",
+		"<!-- gone -->", "",
+		"<v>1</v>", "<v>2</v>",
+	).Replace(src)
+	if buf.String() != want {
+		t.Error("edited:", buf.String())
+	}
+	// added and moved nodes are written again
+	MoveBefore(c, beans[0])
+	d.Root().AddEle(NewEle(NewName("", "last"), nil))
+	buf.Reset()
+	d.WriteMinimal([]byte(src), &buf)
+	re, err := ParseString(buf.String())
+	if err != nil {
+		t.Fatal(err, buf.String())
+	}
+	if re.ToString() != d.ToString() {
+		t.Error("moved:", buf.String())
+	}
+}
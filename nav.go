@@ -0,0 +1,287 @@
+package gdom
+
+func nextSibling(n Node) Node {
+	if !attached(n) {
+		return nil
+	}
+	return n.GetParent().getNodes().at(n.index() + 1)
+}
+
+func prevSibling(n Node) Node {
+	if !attached(n) {
+		return nil
+	}
+	return n.GetParent().getNodes().at(n.index() - 1)
+}
+
+func nextSiblingEle(n Node) *Ele {
+	for x := nextSibling(n); x != nil; x = nextSibling(x) {
+		e, ok := x.(*Ele)
+		if ok {
+			return e
+		}
+	}
+	return nil
+}
+
+func prevSiblingEle(n Node) *Ele {
+	for x := prevSibling(n); x != nil; x = prevSibling(x) {
+		e, ok := x.(*Ele)
+		if ok {
+			return e
+		}
+	}
+	return nil
+}
+
+func firstChild(e Iparent) Node {
+	return e.getNodes().Front()
+}
+
+func lastChild(e Iparent) Node {
+	return e.getNodes().Back()
+}
+
+func parentEle(n Node) *Ele {
+	e, _ := n.GetParent().(*Ele)
+	return e
+}
+
+func ownerDoc(n Node) *Doc {
+	for p := n.GetParent(); p != nil; {
+		switch pp := p.(type) {
+		case *Doc:
+			return pp
+		case *Ele:
+			p = pp.parent
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func ancestors(n Node) []*Ele {
+	rt := make([]*Ele, 0, 8)
+	for e := parentEle(n); e != nil; e = parentEle(e) {
+		rt = append(rt, e)
+	}
+	return rt
+}
+
+func index(n Node) int {
+	if !attached(n) {
+		return -1
+	}
+	return n.index()
+}
+
+// the first child node of the doc
+func (d *Doc) FirstChild() Node {
+	return firstChild(d)
+}
+
+// the last child node of the doc
+func (d *Doc) LastChild() Node {
+	return lastChild(d)
+}
+
+func (e *Ele) FirstChild() Node {
+	return firstChild(e)
+}
+
+func (e *Ele) LastChild() Node {
+	return lastChild(e)
+}
+
+func (p *CharData) FirstChild() Node {
+	return nil
+}
+
+func (p *CharData) LastChild() Node {
+	return nil
+}
+
+func (p *Comment) FirstChild() Node {
+	return nil
+}
+
+func (p *Comment) LastChild() Node {
+	return nil
+}
+
+func (p *ProcInst) FirstChild() Node {
+	return nil
+}
+
+func (p *ProcInst) LastChild() Node {
+	return nil
+}
+
+func (p *Directive) FirstChild() Node {
+	return nil
+}
+
+func (p *Directive) LastChild() Node {
+	return nil
+}
+
+func (e *Ele) NextSibling() Node {
+	return nextSibling(e)
+}
+
+func (e *Ele) PrevSibling() Node {
+	return prevSibling(e)
+}
+
+func (e *Ele) NextSiblingEle() *Ele {
+	return nextSiblingEle(e)
+}
+
+func (e *Ele) PrevSiblingEle() *Ele {
+	return prevSiblingEle(e)
+}
+
+func (e *Ele) ParentEle() *Ele {
+	return parentEle(e)
+}
+
+func (e *Ele) OwnerDoc() *Doc {
+	return ownerDoc(e)
+}
+
+func (e *Ele) Ancestors() []*Ele {
+	return ancestors(e)
+}
+
+func (e *Ele) Index() int {
+	return index(e)
+}
+
+func (p *CharData) NextSibling() Node {
+	return nextSibling(p)
+}
+
+func (p *CharData) PrevSibling() Node {
+	return prevSibling(p)
+}
+
+func (p *CharData) NextSiblingEle() *Ele {
+	return nextSiblingEle(p)
+}
+
+func (p *CharData) PrevSiblingEle() *Ele {
+	return prevSiblingEle(p)
+}
+
+func (p *CharData) ParentEle() *Ele {
+	return parentEle(p)
+}
+
+func (p *CharData) OwnerDoc() *Doc {
+	return ownerDoc(p)
+}
+
+func (p *CharData) Ancestors() []*Ele {
+	return ancestors(p)
+}
+
+func (p *CharData) Index() int {
+	return index(p)
+}
+
+func (p *Comment) NextSibling() Node {
+	return nextSibling(p)
+}
+
+func (p *Comment) PrevSibling() Node {
+	return prevSibling(p)
+}
+
+func (p *Comment) NextSiblingEle() *Ele {
+	return nextSiblingEle(p)
+}
+
+func (p *Comment) PrevSiblingEle() *Ele {
+	return prevSiblingEle(p)
+}
+
+func (p *Comment) ParentEle() *Ele {
+	return parentEle(p)
+}
+
+func (p *Comment) OwnerDoc() *Doc {
+	return ownerDoc(p)
+}
+
+func (p *Comment) Ancestors() []*Ele {
+	return ancestors(p)
+}
+
+func (p *Comment) Index() int {
+	return index(p)
+}
+
+func (p *ProcInst) NextSibling() Node {
+	return nextSibling(p)
+}
+
+func (p *ProcInst) PrevSibling() Node {
+	return prevSibling(p)
+}
+
+func (p *ProcInst) NextSiblingEle() *Ele {
+	return nextSiblingEle(p)
+}
+
+func (p *ProcInst) PrevSiblingEle() *Ele {
+	return prevSiblingEle(p)
+}
+
+func (p *ProcInst) ParentEle() *Ele {
+	return parentEle(p)
+}
+
+func (p *ProcInst) OwnerDoc() *Doc {
+	return ownerDoc(p)
+}
+
+func (p *ProcInst) Ancestors() []*Ele {
+	return ancestors(p)
+}
+
+func (p *ProcInst) Index() int {
+	return index(p)
+}
+
+func (p *Directive) NextSibling() Node {
+	return nextSibling(p)
+}
+
+func (p *Directive) PrevSibling() Node {
+	return prevSibling(p)
+}
+
+func (p *Directive) NextSiblingEle() *Ele {
+	return nextSiblingEle(p)
+}
+
+func (p *Directive) PrevSiblingEle() *Ele {
+	return prevSiblingEle(p)
+}
+
+func (p *Directive) ParentEle() *Ele {
+	return parentEle(p)
+}
+
+func (p *Directive) OwnerDoc() *Doc {
+	return ownerDoc(p)
+}
+
+func (p *Directive) Ancestors() []*Ele {
+	return ancestors(p)
+}
+
+func (p *Directive) Index() int {
+	return index(p)
+}
@@ -0,0 +1,41 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestNavigation(t *testing.T) {
+	d, _ := ParseString(`<p><a/>text<!--c--><b><c/></b></p>`)
+	r := d.Root()
+	a := r.FirstChild()
+	cd := a.NextSibling()
+	if _, ok := cd.(*CharData); !ok {
+		t.Error("next sibling should be chardata")
+		return
+	}
+	if cd.NextSiblingEle().Name.Local != "b" || cd.PrevSiblingEle().Name.Local != "a" {
+		t.Error("sibling ele failed")
+	}
+	if cd.Index() != 1 || r.LastChild().Index() != 3 {
+		t.Error("index failed")
+	}
+	b := r.LastChild().(*Ele)
+	c := b.FirstChild()
+	if c.ParentEle() != b || c.OwnerDoc() != d || cd.OwnerDoc() != d {
+		t.Error("parent failed")
+	}
+	as := c.Ancestors()
+	if len(as) != 2 || as[0] != b || as[1] != r {
+		t.Error("ancestors failed")
+	}
+	if r.ParentEle() != nil || r.OwnerDoc() != d || d.FirstChild() != Node(r) {
+		t.Error("root navigation failed")
+	}
+	if cd.FirstChild() != nil || c.PrevSibling() != nil || c.NextSibling() != nil {
+		t.Error("should be nil")
+	}
+	n := NewEle(NewName("", "n"), nil)
+	if n.OwnerDoc() != nil || n.Index() != -1 || n.ParentEle() != nil {
+		t.Error("detached navigation failed")
+	}
+}
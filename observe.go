@@ -0,0 +1,133 @@
+package gdom
+
+import "slices"
+
+type MutationKind int
+
+const (
+	ChildAdded MutationKind = iota
+	ChildRemoved
+	AttrChanged
+	TextChanged
+	RootReplaced
+)
+
+var mutationKindNames = [...]string{
+	ChildAdded:   "child-added",
+	ChildRemoved: "child-removed",
+	AttrChanged:  "attr-changed",
+	TextChanged:  "text-changed",
+	RootReplaced: "root-replaced",
+}
+
+func (k MutationKind) String() string {
+	if k < 0 || int(k) >= len(mutationKindNames) {
+		return "unknown"
+	}
+	return mutationKindNames[k]
+}
+
+// Mutation is one change of a doc, it is given to the observers after the change
+type Mutation struct {
+	Kind MutationKind
+	// the *Ele or *Doc the change is in: the parent of the child or of the
+	// CharData, the element of the attr, the doc of the root
+	Target Iparent
+	// the child added or removed, the *CharData whose text changed, the new root
+	// of RootReplaced (nil if there is none left)
+	Node Node
+	// the index of the child added or removed, or of the attr
+	Index int
+	// the attr of AttrChanged before and after, OldAttr is nil for an added attr
+	// and NewAttr is nil for a removed one
+	OldAttr, NewAttr *Attr
+	// the text of TextChanged before
+	OldText string
+	// the root of RootReplaced before
+	OldRoot *Ele
+}
+
+type observer struct {
+	f func(Mutation)
+	// only the mutations in the subtree of scope, all if scope is nil
+	scope *Ele
+}
+
+// Observe calls f after every mutation of d that a transaction would record, see
+// Begin, including those of Undo, Redo and Rollback. the children materialized
+// from the raw content of ParseLazy or PDoc.Doc are not mutations.
+// f must not mutate d. Observe returns the func that stops the calls
+func (d *Doc) Observe(f func(Mutation)) func() {
+	return d.observe(&observer{f: f})
+}
+
+// ObserveSubtree is Observe for the mutations inside e: of the children,
+// the attrs and the text of e and of its descendants, as long as e is in d
+func (d *Doc) ObserveSubtree(e *Ele, f func(Mutation)) func() {
+	return d.observe(&observer{f: f, scope: e})
+}
+
+func (d *Doc) observe(o *observer) func() {
+	d.observers = append(d.observers, o)
+	return func() {
+		i := slices.Index(d.observers, o)
+		if i < 0 {
+			return
+		}
+		// a new slice, notify may be ranging over the old one
+		d.observers = slices.Delete(slices.Clone(d.observers), i, i+1)
+	}
+}
+
+func (d *Doc) notify(c change) {
+	m := c.mutation()
+	for _, o := range d.observers {
+		if o.scope == nil || within(m.Target, o.scope) {
+			o.f(m)
+		}
+	}
+}
+
+func (c change) mutation() Mutation {
+	m := Mutation{
+		Target:  c.parent,
+		Node:    c.node,
+		Index:   c.index,
+		OldAttr: c.oldAttr,
+		NewAttr: c.newAttr,
+		OldText: c.oldText,
+		OldRoot: c.oldRoot,
+	}
+	switch c.kind {
+	case childAdded:
+		m.Kind = ChildAdded
+	case childRemoved:
+		m.Kind = ChildRemoved
+	case attrAdded, attrReplaced, attrRemoved:
+		m.Kind = AttrChanged
+	case textChanged:
+		m.Kind = TextChanged
+	case rootReplaced:
+		m.Kind = RootReplaced
+		m.Node = nil
+		if c.newRoot != nil {
+			m.Node = c.newRoot
+		}
+	}
+	return m
+}
+
+// within reports whether p is e or a descendant of e
+func within(p Iparent, e *Ele) bool {
+	for p != nil {
+		pe, ok := p.(*Ele)
+		if !ok {
+			return false
+		}
+		if pe == e {
+			return true
+		}
+		p = pe.parent
+	}
+	return false
+}
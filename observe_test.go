@@ -0,0 +1,71 @@
+package gdom
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestObserve(t *testing.T) {
+	d, _ := ParseString(`<r><a k="1">x</a><b/></r>`)
+	r := d.Root()
+	a := r.AllEles()[0]
+	b := r.AllEles()[1]
+	var all, sub []Mutation
+	stop := d.Observe(func(m Mutation) {
+		all = append(all, m)
+	})
+	stopSub := d.ObserveSubtree(a, func(m Mutation) {
+		sub = append(sub, m)
+	})
+	defer stopSub()
+	a.SetAttr(NewAttr(NewName("", "k"), "2"))
+	a.AddCharDataStr("y")
+	b.AddEle(NewEle(NewName("", "c"), nil))
+	r.RemoveNode(b)
+	d.SetRoot(NewEle(NewName("", "s"), nil))
+	kinds := make([]MutationKind, 0, len(all))
+	for _, m := range all {
+		kinds = append(kinds, m.Kind)
+	}
+	want := []MutationKind{AttrChanged, TextChanged, ChildAdded, ChildRemoved, ChildAdded, ChildRemoved, RootReplaced}
+	if !slices.Equal(kinds, want) {
+		t.Error("mutations:", kinds)
+	}
+	if m := all[0]; m.Target != a || m.OldAttr.Value != "1" || m.NewAttr.Value != "2" {
+		t.Error("attr mutation:", m)
+	}
+	if m := all[1]; m.Target != a || m.OldText != "x" || m.Node.(*CharData).V != "xy" {
+		t.Error("text mutation:", m)
+	}
+	if m := all[3]; m.Target != r || m.Node != b || m.Index != 1 {
+		t.Error("remove mutation:", m)
+	}
+	if m := all[6]; m.OldRoot != r || m.Node != d.Root() {
+		t.Error("root mutation:", m)
+	}
+	if len(sub) != 2 {
+		t.Error("subtree mutations:", len(sub))
+	}
+	stop()
+	stop()
+	d.Root().SetAttr(NewAttr(NewName("", "k"), "3"))
+	if len(all) != 7 {
+		t.Error("stopped observer called")
+	}
+}
+
+func TestObserveUndo(t *testing.T) {
+	d, _ := ParseString(`<r><a/></r>`)
+	tx, _ := d.Begin()
+	d.Root().RemoveAllNodes()
+	tx.Commit()
+	var ms []Mutation
+	stop := d.Observe(func(m Mutation) {
+		ms = append(ms, m)
+	})
+	defer stop()
+	d.Undo()
+	if len(ms) != 1 || ms[0].Kind != ChildAdded || ms[0].Node.(*Ele).Name.Local != "a" {
+		t.Error("undo mutations:", ms)
+	}
+}
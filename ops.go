@@ -0,0 +1,268 @@
+package gdom
+
+// the operations below move the nodes themselves instead of copies, a node is
+// detached from its current parent first. like addCharData, CharData that ends
+// up next to another CharData is merged into it
+
+// ReplaceWith puts nodes in the place of old and detaches old
+func ReplaceWith(old Node, nodes ...Node) error {
+	p, err := attachedParent(old)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if contains(n, old) {
+			return treeErr("ReplaceWith", n, ErrCycle)
+		}
+		if isRoot(n) {
+			return treeErr("ReplaceWith", n, ErrRoot)
+		}
+		if err := frozenErr("ReplaceWith", n, n.GetParent()); err != nil {
+			return err
+		}
+	}
+	if _, ok := p.(*Doc); ok && countEles(nodes) != countEles([]Node{old}) {
+		return treeErr("ReplaceWith", old, ErrRoot)
+	}
+	marker := attach(p, NewComment(""), old)
+	detach(old)
+	for _, n := range nodes {
+		moveTo(n, p, marker)
+	}
+	detach(marker)
+	fixRoot(p)
+	return nil
+}
+
+// Wrap puts wrapper in the place of n and moves n into it, as its last child
+func Wrap(n Node, wrapper *Ele) error {
+	p, err := attachedParent(n)
+	if err != nil {
+		return err
+	}
+	if contains(wrapper, n) {
+		return treeErr("Wrap", wrapper, ErrCycle)
+	}
+	if isRoot(wrapper) {
+		return treeErr("Wrap", wrapper, ErrRoot)
+	}
+	if err := frozenErr("Wrap", wrapper, wrapper.parent); err != nil {
+		return err
+	}
+	if _, ok := n.(*Ele); !ok {
+		if _, ok := p.(*Doc); ok {
+			return treeErr("Wrap", n, ErrRoot)
+		}
+	}
+	moveTo(wrapper, p, n)
+	moveTo(n, wrapper, nil)
+	fixRoot(p)
+	return nil
+}
+
+// Unwrap puts the children of e in the place of e and detaches e
+func Unwrap(e *Ele) error {
+	p, err := attachedParent(e)
+	if err != nil {
+		return err
+	}
+	nodes := e.AllNodes()
+	if _, ok := p.(*Doc); ok && countEles(nodes) != 1 {
+		return treeErr("Unwrap", e, ErrRoot)
+	}
+	for _, n := range nodes {
+		moveTo(n, p, e)
+	}
+	detach(e)
+	fixRoot(p)
+	return nil
+}
+
+// MoveBefore moves n in front of pos
+func MoveBefore(n, pos Node) error {
+	p, err := checkMove(n, pos)
+	if err != nil {
+		return err
+	}
+	moveTo(n, p, pos)
+	return nil
+}
+
+// MoveAfter moves n behind pos
+func MoveAfter(n, pos Node) error {
+	p, err := checkMove(n, pos)
+	if err != nil {
+		return err
+	}
+	moveTo(n, p, nextSibling(pos))
+	return nil
+}
+
+// Swap exchanges the places of a and b
+func Swap(a, b Node) error {
+	pa, err := attachedParent(a)
+	if err != nil {
+		return err
+	}
+	pb, err := attachedParent(b)
+	if err != nil {
+		return err
+	}
+	if contains(a, b) || contains(b, a) {
+		return treeErr("Swap", a, ErrCycle)
+	}
+	_, da := pa.(*Doc)
+	_, db := pb.(*Doc)
+	if da != db && countEles([]Node{a}) != countEles([]Node{b}) {
+		return treeErr("Swap", a, ErrRoot)
+	}
+	ma := attach(pa, NewComment(""), a)
+	mb := attach(pb, NewComment(""), b)
+	moveTo(a, pb, mb)
+	moveTo(b, pa, ma)
+	detach(ma)
+	detach(mb)
+	fixRoot(pa)
+	fixRoot(pb)
+	return nil
+}
+
+func checkMove(n, pos Node) (Iparent, error) {
+	p, err := attachedParent(pos)
+	if err != nil {
+		return nil, err
+	}
+	if n == pos || contains(n, pos) {
+		return nil, treeErr("Move", n, ErrCycle)
+	}
+	if _, ok := p.(*Doc); ok && n.GetParent() != p && countEles([]Node{n}) > 0 {
+		return nil, treeErr("Move", n, ErrRoot)
+	}
+	if isRoot(n) && n.GetParent() != p {
+		return nil, treeErr("Move", n, ErrRoot)
+	}
+	if err := frozenErr("Move", n, n.GetParent()); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func attachedParent(n Node) (Iparent, error) {
+	p := n.GetParent()
+	if p == nil || !attached(n) {
+		return nil, treeErr("Move", n, ErrDetached)
+	}
+	if err := frozenErr("Move", n, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// contains reports whether b is a or below a
+func contains(a, b Node) bool {
+	if a == b {
+		return true
+	}
+	e, ok := a.(*Ele)
+	if !ok {
+		return false
+	}
+	for p := parentEle(b); p != nil; p = parentEle(p) {
+		if p == e {
+			return true
+		}
+	}
+	return false
+}
+
+func isRoot(n Node) bool {
+	d, ok := n.GetParent().(*Doc)
+	return ok && n == Node(d.root)
+}
+
+func countEles(nodes []Node) int {
+	cnt := 0
+	for _, n := range nodes {
+		if _, ok := n.(*Ele); ok {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// fixRoot makes the root of a doc the *Ele it holds
+func fixRoot(p Iparent) {
+	d, ok := p.(*Doc)
+	if !ok {
+		return
+	}
+	for _, x := range d.nodes {
+		e, ok := x.(*Ele)
+		if ok {
+			setRoot(d, e)
+			return
+		}
+	}
+	setRoot(d, nil)
+}
+
+// moveTo detaches n and attaches it to p before the node before, or at the end
+// if before is nil. a marker keeps the place while the CharData around n is merged
+func moveTo(n Node, p Iparent, before Node) {
+	marker := attach(p, NewComment(""), before)
+	detach(n)
+	attach(p, n, marker)
+	detach(marker)
+}
+
+// detach removes n from its parent and merges the CharData around it
+func detach(n Node) {
+	p := n.GetParent()
+	if p == nil || !attached(n) {
+		return
+	}
+	prev := prevSibling(n)
+	removeNode(p, n)
+	joinText(p, prev)
+}
+
+// joinText merges the node after x into x if both are CharData
+func joinText(p Iparent, x Node) {
+	if x == nil {
+		return
+	}
+	c1, ok1 := x.(*CharData)
+	c2, ok2 := mergeable(nextSibling(x), c1)
+	if ok1 && ok2 {
+		setText(c1, c1.V+c2.V)
+		removeNode(p, c2)
+	}
+}
+
+// attach inserts n itself into p before the node before, or at the end if before
+// is nil. CharData is merged into a CharData next to it, it returns the node holding n
+func attach(p Iparent, n Node, before Node) Node {
+	cd, ok := n.(*CharData)
+	if ok {
+		prev := p.getNodes().Back()
+		if before != nil {
+			prev = prevSibling(before)
+		}
+		pc, ok := mergeable(prev, cd)
+		if ok {
+			setText(pc, pc.V+cd.V)
+			return pc
+		}
+		nc, ok := mergeable(before, cd)
+		if ok {
+			setText(nc, cd.V+nc.V)
+			return nc
+		}
+	}
+	if before == nil {
+		pushNode(p, n)
+	} else {
+		insertNode(p, before.index(), n)
+	}
+	return n
+}
@@ -0,0 +1,64 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestReplaceWrapUnwrap(t *testing.T) {
+	d, _ := ParseString(`<p>x<a/>y<b><c/>z</b>w</p>`)
+	r := d.Root()
+	a := r.ElesByStrName("", "a")[0]
+	err := ReplaceWith(a, NewCharData("1"), NewEle(NewName("", "n"), nil))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d.ToString() != "<p>x1<n/>y<b><c/>z</b>w</p>" || len(r.AllCharData()) != 3 {
+		t.Error("replace failed", d.ToString())
+	}
+	b := r.ElesByStrName("", "b")[0]
+	err = Unwrap(b)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d.ToString() != "<p>x1<n/>y<c/>zw</p>" || len(r.AllCharData()) != 3 {
+		t.Error("unwrap failed", d.ToString())
+	}
+	w := NewEle(NewName("", "w"), nil)
+	err = Wrap(r, w)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if d.Root() != w || d.ToString() != "<w><p>x1<n/>y<c/>zw</p></w>" {
+		t.Error("wrap failed", d.ToString())
+	}
+	if Wrap(r, NewEle(NewName("", "q"), nil)) != nil || Wrap(r, w) == nil {
+		t.Error("wrap into a descendant should fail")
+	}
+}
+
+func TestMoveSwap(t *testing.T) {
+	d, _ := ParseString(`<p><a/>1<b/>2<c><d/></c></p>`)
+	r := d.Root()
+	a := r.ElesByStrName("", "a")[0]
+	b := r.ElesByStrName("", "b")[0]
+	c := r.ElesByStrName("", "c")[0]
+	dd := c.ElesByStrName("", "d")[0]
+	MoveAfter(a, dd)
+	if d.ToString() != "<p>1<b/>2<c><d/><a/></c></p>" {
+		t.Error("move after failed", d.ToString())
+	}
+	MoveBefore(b, r.FirstChild())
+	if d.ToString() != "<p><b/>12<c><d/><a/></c></p>" || len(r.AllCharData()) != 1 {
+		t.Error("move before failed", d.ToString())
+	}
+	Swap(b, a)
+	if d.ToString() != "<p><a/>12<c><d/><b/></c></p>" {
+		t.Error("swap failed", d.ToString())
+	}
+	if Swap(c, dd) == nil || MoveBefore(c, dd) == nil {
+		t.Error("moving into a descendant should fail")
+	}
+}
@@ -0,0 +1,160 @@
+package gdom
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	OverlayMerge   = "merge"
+	OverlayReplace = "replace"
+	OverlayRemove  = "remove"
+)
+
+// OverlayRules controls Overlay, nil means the zero value
+type OverlayRules struct {
+	// Keys are attributes used to match elements of the overlay with the base,
+	// like "bean@id" or "property@name". elements without a key are matched by name and position
+	Keys []string
+	// the attr of overlay elements holding the action, merge:action if empty.
+	// the action is one of OverlayMerge (the default), OverlayReplace or OverlayRemove
+	Action Name
+}
+
+// Overlay returns a copy of base with the elements of overlay laid over it.
+// a matched element is merged by default: the overlay attrs override the base ones,
+// text replaces the base text and the children are laid over recursively.
+// with merge:action="replace" the element replaces the base one and with
+// merge:action="remove" the base element is removed. unmatched elements are added
+// after the element that precedes them in the overlay
+func Overlay(base, overlay *Doc, rules *OverlayRules) (*Doc, error) {
+	ov := &overlayer{}
+	if rules != nil {
+		ov.rules = *rules
+	}
+	if ov.rules.Action == (Name{}) {
+		ov.rules.Action = NewName("merge", "action")
+	}
+	ov.keys = parseKeys(ov.rules.Keys)
+	rt := base.Copy()
+	r, o := rt.Root(), overlay.Root()
+	if r == nil || o == nil {
+		return nil, errors.New("overlay: no root element")
+	}
+	if r.Name != o.Name {
+		return nil, errors.New("overlay: root element " + nameString(o.Name) + " doesn't match " + nameString(r.Name))
+	}
+	switch ov.action(o) {
+	case OverlayMerge:
+		err := ov.merge(r, o)
+		if err != nil {
+			return nil, err
+		}
+	case OverlayReplace:
+		cp := ov.strip(o)
+		insertBefore(rt, cp, r)
+		nr := prevSibling(r).(*Ele)
+		removeNode(rt, r)
+		setRoot(rt, nr)
+	case OverlayRemove:
+		return nil, errors.New("overlay: can't remove the root element")
+	default:
+		return nil, errors.New("overlay: unknown action " + ov.action(o))
+	}
+	return rt, nil
+}
+
+type overlayer struct {
+	rules OverlayRules
+	keys  []keyRule
+}
+
+func (ov *overlayer) action(e *Ele) string {
+	v, ok := e.GetAttr(ov.rules.Action)
+	if !ok {
+		return OverlayMerge
+	}
+	return v
+}
+
+// isDirective reports whether the attr a only serves the overlay
+func (ov *overlayer) isDirective(a Name) bool {
+	if a == ov.rules.Action {
+		return true
+	}
+	return ov.rules.Action.Space != "" && a.Space == "xmlns" && a.Local == ov.rules.Action.Space
+}
+
+// strip returns a copy of e without the overlay directives
+func (ov *overlayer) strip(e *Ele) *Ele {
+	cp := e.Copy().(*Ele)
+	ov.stripEle(cp)
+	return cp
+}
+
+func (ov *overlayer) stripEle(e *Ele) {
+	for _, a := range e.allAttrs() {
+		if ov.isDirective(a.Name) {
+			e.RemoveAttr(a)
+		}
+	}
+	for _, c := range e.AllEles() {
+		ov.stripEle(c)
+	}
+}
+
+func (ov *overlayer) merge(r, o *Ele) error {
+	for _, a := range o.allAttrs() {
+		if !ov.isDirective(a.Name) {
+			r.SetAttr(NewAttr(a.Name, a.Value))
+		}
+	}
+	oe := o.AllEles()
+	if len(oe) == 0 {
+		if strings.TrimSpace(o.Text()) != "" && len(r.AllEles()) == 0 {
+			replaceText(r, o)
+		}
+		return nil
+	}
+	rs := make(map[string]*Ele)
+	count := make(map[string]int)
+	for _, c := range r.AllEles() {
+		_, key := eleStep(ov.keys, c, count)
+		rs[key] = c
+	}
+	order := make([]string, 0, len(oe))
+	count = make(map[string]int)
+	for _, c := range oe {
+		_, key := eleStep(ov.keys, c, count)
+		order = append(order, key)
+		re, ok := rs[key]
+		act := ov.action(c)
+		switch act {
+		case OverlayMerge:
+			if ok {
+				err := ov.merge(re, c)
+				if err != nil {
+					return err
+				}
+			} else {
+				rs[key] = insertLike(r, rs, order[:len(order)-1], ov.strip(c), leadingSpace(c))
+			}
+		case OverlayReplace:
+			if ok {
+				insertBefore(r, ov.strip(c), re)
+				rs[key] = prevSibling(re).(*Ele)
+				removeNode(r, re)
+			} else {
+				rs[key] = insertLike(r, rs, order[:len(order)-1], ov.strip(c), leadingSpace(c))
+			}
+		case OverlayRemove:
+			if ok {
+				removeWhitespace(r, prevSibling(re))
+				removeNode(r, re)
+			}
+		default:
+			return errors.New("overlay: unknown action " + act)
+		}
+	}
+	return nil
+}
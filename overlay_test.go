@@ -0,0 +1,54 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	base, _ := ParseString(`<beans>
+    <bean id="ds" class="BasicDataSource">
+        <property name="url" value="dev"/>
+        <property name="username" value="dev"/>
+    </bean>
+    <bean id="debug" class="Debug"/>
+    <bean id="cache" class="Cache"><property name="size" value="1"/></bean>
+</beans>`)
+	prod, _ := ParseString(`<beans xmlns:merge="urn:gdom:merge">
+    <bean id="ds">
+        <property name="url" value="prod"/>
+        <property name="maxActive" value="100"/>
+    </bean>
+    <bean id="debug" merge:action="remove"/>
+    <bean id="cache" class="BigCache" merge:action="replace"/>
+    <bean id="audit" class="Audit"/>
+</beans>`)
+	rt, err := Overlay(base, prod, &OverlayRules{Keys: []string{"bean@id", "property@name"}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rs := `<beans>
+    <bean id="ds" class="BasicDataSource">
+        <property name="url" value="prod"/>
+        <property name="maxActive" value="100"/>
+        <property name="username" value="dev"/>
+    </bean>
+    <bean id="cache" class="BigCache"/>
+    <bean id="audit" class="Audit"/>
+</beans>`
+	if rt.ToString() != rs {
+		t.Error("overlay failed")
+		t.Error(rt.ToString())
+	}
+}
+
+func TestOverlayNoRoot(t *testing.T) {
+	base, _ := ParseString(`<beans/>`)
+	empty, _ := ParseString(`<!--no root-->`)
+	if _, err := Overlay(base, empty, nil); err == nil {
+		t.Error("overlay without a root")
+	}
+	if _, err := Overlay(empty, base, nil); err == nil {
+		t.Error("base without a root")
+	}
+}
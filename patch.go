@@ -0,0 +1,770 @@
+package gdom
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ApplyPatch applies an RFC 5261 patch document to doc.
+// the root of patch holds <add>, <replace> and <remove> operations, each with a sel attr.
+// the patch is applied to a copy first, so on any failure doc is left untouched
+func ApplyPatch(doc, patch *Doc) error {
+	if err := frozenErr("ApplyPatch", nil, doc); err != nil {
+		return err
+	}
+	if patch.Root() == nil {
+		return errors.New("patch: empty patch document")
+	}
+	err := applyPatch(doc.Copy(), patch)
+	if err != nil {
+		return err
+	}
+	return applyPatch(doc, patch)
+}
+
+func applyPatch(d *Doc, patch *Doc) error {
+	for _, op := range patch.Root().AllEles() {
+		sel, ok := op.GetAttrByStrName("", "sel")
+		if !ok {
+			return errors.New("patch: <" + nameString(op.Name) + "> without sel")
+		}
+		var err error
+		switch op.Name.Local {
+		case "add":
+			err = patchAdd(d, op, sel)
+		case "replace":
+			err = patchReplace(d, op, sel)
+		case "remove":
+			err = patchRemove(d, op, sel)
+		default:
+			err = errors.New("unknown operation")
+		}
+		if err != nil {
+			return errors.New("patch: <" + nameString(op.Name) + " sel=\"" + sel + "\">: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func patchAdd(d *Doc, op *Ele, sel string) error {
+	t, err := selectOne(d, sel)
+	if err != nil {
+		return err
+	}
+	if t.isAttr {
+		return errors.New("can't add to an attribute")
+	}
+	tp, ok := op.GetAttrByStrName("", "type")
+	if ok {
+		ele, ok := t.node.(*Ele)
+		if !ok {
+			return errors.New("type is only allowed on elements")
+		}
+		if !strings.HasPrefix(tp, "@") {
+			return errors.New("unsupported type " + tp)
+		}
+		name := parseName(tp[1:])
+		if _, ok := ele.GetAttr(name); ok {
+			return errors.New("attribute " + tp + " already exists")
+		}
+		ele.SetAttr(NewAttr(name, op.Text()))
+		return nil
+	}
+	nodes := op.AllNodes()
+	pos, _ := op.GetAttrByStrName("", "pos")
+	switch pos {
+	case "":
+		ele, ok := t.node.(*Ele)
+		if !ok {
+			return errors.New("can only append to an element")
+		}
+		for _, n := range nodes {
+			ele.AddNode(n)
+		}
+	case "prepend":
+		ele, ok := t.node.(*Ele)
+		if !ok {
+			return errors.New("can only prepend to an element")
+		}
+		first := ele.getNodes().Front()
+		for _, n := range nodes {
+			if first == nil {
+				ele.AddNode(n)
+				continue
+			}
+			err = insertBefore(ele, n, first)
+			if err != nil {
+				return err
+			}
+		}
+	case "before", "after":
+		if t.node == nil {
+			return errors.New("can't add siblings to the document")
+		}
+		if _, isDoc := t.parent.(*Doc); isDoc {
+			for _, n := range nodes {
+				if _, ok := n.(*Ele); ok {
+					return errors.New("can't add a second root element")
+				}
+			}
+		}
+		if pos == "before" {
+			for _, n := range nodes {
+				err = insertBefore(t.parent, n, t.node)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			for i := len(nodes) - 1; i >= 0; i-- {
+				err = insertAfter(t.parent, nodes[i], t.node)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return errors.New("unknown pos " + pos)
+	}
+	return nil
+}
+
+func patchReplace(d *Doc, op *Ele, sel string) error {
+	t, err := selectOne(d, sel)
+	if err != nil {
+		return err
+	}
+	if t.isAttr {
+		ele := t.node.(*Ele)
+		ele.SetAttr(NewAttr(t.attr, op.Text()))
+		return nil
+	}
+	switch n := t.node.(type) {
+	case nil:
+		return errors.New("can't replace the document")
+	case *CharData:
+		setText(n, op.Text())
+		if n.V == "" {
+			return removeNode(t.parent, n)
+		}
+		return nil
+	}
+	var rep Node
+	for _, c := range op.AllNodes() {
+		if cd, ok := c.(*CharData); ok && strings.TrimSpace(cd.V) == "" {
+			continue
+		}
+		if rep != nil {
+			return errors.New("replace needs exactly one node")
+		}
+		rep = c
+	}
+	if rep == nil {
+		return errors.New("replace needs exactly one node")
+	}
+	if nodeKind(rep) != nodeKind(t.node) {
+		return errors.New("replace needs a node of the same type")
+	}
+	err = insertBefore(t.parent, rep, t.node)
+	if err != nil {
+		return err
+	}
+	nn := prevSibling(t.node)
+	err = removeNode(t.parent, t.node)
+	if err != nil {
+		return err
+	}
+	doc, ok := t.parent.(*Doc)
+	if ok && t.node == Node(doc.root) {
+		setRoot(doc, nn.(*Ele))
+	}
+	return nil
+}
+
+func patchRemove(d *Doc, op *Ele, sel string) error {
+	t, err := selectOne(d, sel)
+	if err != nil {
+		return err
+	}
+	ele, _ := t.node.(*Ele)
+	if t.isAttr {
+		ele.RemoveAttrByName(t.attr)
+		return nil
+	}
+	if t.node == nil {
+		return errors.New("can't remove the document")
+	}
+	if doc, ok := t.parent.(*Doc); ok && ele != nil && ele == doc.root {
+		return errors.New("can't remove the root element")
+	}
+	ws, _ := op.GetAttrByStrName("", "ws")
+	if ws == "before" || ws == "both" {
+		removeWhitespace(t.parent, prevSibling(t.node))
+	}
+	if ws == "after" || ws == "both" {
+		removeWhitespace(t.parent, nextSibling(t.node))
+	}
+	return removeNode(t.parent, t.node)
+}
+
+// removeWhitespace removes x from p if it is whitespace only CharData
+func removeWhitespace(p Iparent, x Node) {
+	cd, ok := x.(*CharData)
+	if ok && strings.TrimSpace(cd.V) == "" {
+		removeNode(p, cd)
+	}
+}
+
+// nodeKind names the type of n the way the xpath node tests do
+func nodeKind(n Node) string {
+	switch n.(type) {
+	case *Ele:
+		return "element"
+	case *CharData:
+		return "text"
+	case *Comment:
+		return "comment"
+	case *ProcInst:
+		return "processing-instruction"
+	case *Directive:
+		return "directive"
+	case *DocType:
+		return "doctype"
+	case *EntityRef:
+		return "entity"
+	}
+	return ""
+}
+
+// MakePatch returns an RFC 5261 patch document turning a into b.
+// sibling nodes are aligned by their name or content, changed attributes and
+// text are patched in place and everything else is removed and added again
+func MakePatch(a, b *Doc) *Doc {
+	mp := &patchMaker{
+		patch: NewDoc(NewName("", "diff")),
+	}
+	mp.children(a.Copy(), "", b)
+	return mp.patch
+}
+
+type patchMaker struct {
+	patch *Doc
+}
+
+func (mp *patchMaker) op(name, sel string) *Ele {
+	op := NewEle(NewName("", name), nil)
+	op.SetAttr(NewAttr(NewName("", "sel"), sel))
+	addEle(mp.patch.Root(), op)
+	return op
+}
+
+// signature is what siblings are aligned by, elements only by name
+func signature(n Node, top bool) string {
+	switch nd := n.(type) {
+	case *Ele:
+		if top {
+			return "e:"
+		}
+		return "e:" + nameString(nd.Name)
+	case *CharData:
+		return "t:" + nd.V
+	case *Comment:
+		return "c:" + nd.V
+	case *ProcInst:
+		return "p:" + nd.Target + " " + nd.Inst
+	case *Directive:
+		return "d:" + nd.V
+	case *DocType:
+		return "t:" + nd.directive()
+	case *EntityRef:
+		return "r:" + nd.Name
+	}
+	return ""
+}
+
+// children patches the children of w, which is the working copy of a container
+// at path, to equal the children of b. w is changed along with the patch, so the
+// selectors always fit the document the operation is applied to
+func (mp *patchMaker) children(w Iparent, path string, b Iparent) {
+	_, top := w.(*Doc)
+	wn := allNodes(w)
+	bn := allNodes(b)
+	ws := make([]string, len(wn))
+	bs := make([]string, len(bn))
+	for i, n := range wn {
+		ws[i] = signature(n, top)
+	}
+	for i, n := range bn {
+		bs[i] = signature(n, top)
+	}
+	pairs := lcsPairs(ws, bs)
+	kept := make(map[Node]Node, len(pairs))
+	keptB := make(map[Node]bool, len(pairs))
+	for _, p := range pairs {
+		kept[wn[p[0]]] = bn[p[1]]
+		keptB[bn[p[1]]] = true
+	}
+	for i := len(wn) - 1; i >= 0; i-- {
+		if _, ok := kept[wn[i]]; ok {
+			continue
+		}
+		mp.op("remove", path+"/"+stepOf(w, wn[i]))
+		removeNode(w, wn[i])
+	}
+	// w now holds only the kept nodes, in the order of b. each added node goes
+	// right after the node placed before it, so text is never merged into a
+	// kept CharData that other added nodes still have to precede
+	k := 0
+	for _, n := range bn {
+		kw := allNodes(w)
+		if keptB[n] {
+			k++
+			continue
+		}
+		var op *Ele
+		if k > 0 {
+			op = mp.op("add", path+"/"+stepOf(w, kw[k-1]))
+			op.SetAttr(NewAttr(NewName("", "pos"), "after"))
+			insertAfter(w, n, kw[k-1])
+		} else if len(kw) > 0 {
+			op = mp.op("add", path+"/"+stepOf(w, kw[0]))
+			op.SetAttr(NewAttr(NewName("", "pos"), "before"))
+			insertBefore(w, n, kw[0])
+		} else {
+			op = mp.op("add", path)
+			w.(*Ele).AddNode(n)
+		}
+		op.AddNode(n)
+		if len(allNodes(w)) > len(kw) {
+			k++
+		}
+	}
+	for _, p := range pairs {
+		ea, ok := wn[p[0]].(*Ele)
+		if !ok {
+			continue
+		}
+		eb := bn[p[1]].(*Ele)
+		sel := path + "/" + stepOf(w, ea)
+		if ea.Name != eb.Name {
+			op := mp.op("replace", sel)
+			op.AddEle(eb)
+			insertBefore(w, eb, ea)
+			nn := prevSibling(ea).(*Ele)
+			removeNode(w, ea)
+			if doc, ok := w.(*Doc); ok && doc.root == ea {
+				setRoot(doc, nn)
+			}
+			continue
+		}
+		mp.attrs(ea, sel, eb)
+		mp.children(ea, sel, eb)
+	}
+}
+
+func (mp *patchMaker) attrs(w *Ele, sel string, b *Ele) {
+	for _, attr := range w.allAttrs() {
+		v, ok := b.GetAttr(attr.Name)
+		if !ok {
+			mp.op("remove", sel+"/@"+nameString(attr.Name))
+			w.RemoveAttrByName(attr.Name)
+		} else if v != attr.Value {
+			op := mp.op("replace", sel+"/@"+nameString(attr.Name))
+			op.AddCharDataStr(v)
+			w.SetAttr(NewAttr(attr.Name, v))
+		}
+	}
+	for _, attr := range b.allAttrs() {
+		if _, ok := w.GetAttr(attr.Name); ok {
+			continue
+		}
+		op := mp.op("add", sel)
+		op.SetAttr(NewAttr(NewName("", "type"), "@"+nameString(attr.Name)))
+		op.AddCharDataStr(attr.Value)
+		w.SetAttr(NewAttr(attr.Name, attr.Value))
+	}
+}
+
+// lcsPairs returns the index pairs of a longest common subsequence of a and b
+func lcsPairs(a, b []string) [][2]int {
+	n, m := len(a), len(b)
+	tbl := make([][]int, n+1)
+	for i := range tbl {
+		tbl[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				tbl[i][j] = tbl[i+1][j+1] + 1
+			} else if tbl[i+1][j] >= tbl[i][j+1] {
+				tbl[i][j] = tbl[i+1][j]
+			} else {
+				tbl[i][j] = tbl[i][j+1]
+			}
+		}
+	}
+	rt := make([][2]int, 0, tbl[0][0])
+	for i, j := 0, 0; i < n && j < m; {
+		if a[i] == b[j] {
+			rt = append(rt, [2]int{i, j})
+			i++
+			j++
+		} else if tbl[i+1][j] >= tbl[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+	return rt
+}
+
+// stepOf returns the xpath step selecting n among the children of p
+func stepOf(p Iparent, n Node) string {
+	count := 0
+	for _, c := range *p.getNodes() {
+		if nodeKind(c) != nodeKind(n) {
+			continue
+		}
+		if e, ok := c.(*Ele); ok && e.Name != n.(*Ele).Name {
+			continue
+		}
+		if pi, ok := c.(*ProcInst); ok && pi.Target != n.(*ProcInst).Target {
+			continue
+		}
+		count++
+		if c == n {
+			break
+		}
+	}
+	idx := "[" + strconv.Itoa(count) + "]"
+	switch nd := n.(type) {
+	case *Ele:
+		return nameString(nd.Name) + idx
+	case *ProcInst:
+		return "processing-instruction(" + xpathLiteral(nd.Target) + ")" + idx
+	case *Directive:
+		return "directive()" + idx
+	}
+	return nodeKind(n) + "()" + idx
+}
+
+// selection is a node found by a selector, node is nil for the document itself.
+// if isAttr is true, the attr of the element node is selected
+type selection struct {
+	parent Iparent
+	node   Node
+	isAttr bool
+	attr   Name
+}
+
+// selectOne returns the only node selected by sel
+func selectOne(d *Doc, sel string) (selection, error) {
+	rt, err := selectNodes(d, sel)
+	if err != nil {
+		return selection{}, err
+	}
+	if len(rt) == 0 {
+		return selection{}, errors.New("no node matched")
+	}
+	if len(rt) > 1 {
+		return selection{}, errors.New("more than one node matched")
+	}
+	return rt[0], nil
+}
+
+// selectNodes evaluates the xpath subset used by patches: child steps with a
+// name, *, node(), text(), comment(), processing-instruction() or directive() test,
+// predicates [n], [last()], [@a], [@a='v'] and [child='v'], and a final @attr step
+func selectNodes(d *Doc, sel string) ([]selection, error) {
+	steps, err := splitSteps(sel)
+	if err != nil {
+		return nil, err
+	}
+	cur := []selection{{}}
+	for i, st := range steps {
+		if strings.HasPrefix(st, "@") {
+			if i != len(steps)-1 {
+				return nil, errors.New("attribute step must be the last one")
+			}
+			name := parseName(st[1:])
+			rt := make([]selection, 0, len(cur))
+			for _, s := range cur {
+				e, ok := s.node.(*Ele)
+				if !ok {
+					continue
+				}
+				if _, ok := e.GetAttr(name); ok {
+					rt = append(rt, selection{parent: s.parent, node: e, isAttr: true, attr: name})
+				}
+			}
+			return rt, nil
+		}
+		test, preds, err := parseStep(st)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]selection, 0, len(cur))
+		for _, s := range cur {
+			var p Iparent = d
+			if s.node != nil {
+				e, ok := s.node.(*Ele)
+				if !ok {
+					continue
+				}
+				p = e
+			}
+			cands := make([]Node, 0, 4)
+			for _, n := range *p.getNodes() {
+				ok, err := matchTest(test, n)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					cands = append(cands, n)
+				}
+			}
+			for _, pr := range preds {
+				cands, err = filterPred(cands, pr)
+				if err != nil {
+					return nil, err
+				}
+			}
+			for _, c := range cands {
+				next = append(next, selection{parent: p, node: c})
+			}
+		}
+		cur = next
+	}
+	return cur, nil
+}
+
+// splitSteps splits sel at the '/' outside of predicates and literals
+func splitSteps(sel string) ([]string, error) {
+	sel = strings.TrimSpace(sel)
+	if strings.HasPrefix(sel, "/") {
+		sel = sel[1:]
+	}
+	if sel == "" {
+		return nil, nil
+	}
+	rt := make([]string, 0, 4)
+	depth := 0
+	var quote byte
+	last := 0
+	for i := 0; i < len(sel); i++ {
+		c := sel[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == '/' && depth == 0:
+			rt = append(rt, sel[last:i])
+			last = i + 1
+		}
+	}
+	rt = append(rt, sel[last:])
+	for _, st := range rt {
+		if st == "" {
+			return nil, errors.New("unsupported selector " + sel)
+		}
+	}
+	return rt, nil
+}
+
+// parseStep splits a step into its node test and predicates
+func parseStep(st string) (string, []string, error) {
+	preds := make([]string, 0, 1)
+	test := ""
+	depth := 0
+	var quote byte
+	start := -1
+	for i := 0; i < len(st); i++ {
+		c := st[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == '[' && depth == 0:
+			if start < 0 && test == "" {
+				test = st[:i]
+			}
+			if start >= 0 {
+				depth++
+				continue
+			}
+			start = i + 1
+		case c == ']' && depth == 0:
+			if start < 0 {
+				return "", nil, errors.New("bad step " + st)
+			}
+			preds = append(preds, strings.TrimSpace(st[start:i]))
+			start = -1
+		}
+	}
+	if start >= 0 || quote != 0 {
+		return "", nil, errors.New("bad step " + st)
+	}
+	if test == "" {
+		test = st
+	}
+	return strings.TrimSpace(test), preds, nil
+}
+
+func matchTest(test string, n Node) (bool, error) {
+	switch test {
+	case "node()":
+		return true, nil
+	case "*":
+		_, ok := n.(*Ele)
+		return ok, nil
+	case "text()":
+		_, ok := n.(*CharData)
+		return ok, nil
+	case "comment()":
+		_, ok := n.(*Comment)
+		return ok, nil
+	case "directive()":
+		_, ok := n.(*Directive)
+		return ok, nil
+	case "doctype()":
+		_, ok := n.(*DocType)
+		return ok, nil
+	case "entity()":
+		_, ok := n.(*EntityRef)
+		return ok, nil
+	}
+	if strings.HasPrefix(test, "processing-instruction(") && strings.HasSuffix(test, ")") {
+		pi, ok := n.(*ProcInst)
+		if !ok {
+			return false, nil
+		}
+		arg := strings.TrimSpace(test[len("processing-instruction(") : len(test)-1])
+		if arg == "" {
+			return true, nil
+		}
+		target, err := parseLiteral(arg)
+		if err != nil {
+			return false, err
+		}
+		return pi.Target == target, nil
+	}
+	if strings.ContainsAny(test, "()") {
+		return false, errors.New("unsupported node test " + test)
+	}
+	e, ok := n.(*Ele)
+	return ok && nameString(e.Name) == test, nil
+}
+
+func filterPred(cands []Node, pr string) ([]Node, error) {
+	if pr == "last()" {
+		if len(cands) == 0 {
+			return cands, nil
+		}
+		return cands[len(cands)-1:], nil
+	}
+	if idx, err := strconv.Atoi(pr); err == nil {
+		if idx < 1 || idx > len(cands) {
+			return cands[:0], nil
+		}
+		return cands[idx-1 : idx], nil
+	}
+	lhs, rhs := pr, ""
+	hasValue := false
+	if i := indexOutsideQuotes(pr, '='); i >= 0 {
+		lhs, rhs = strings.TrimSpace(pr[:i]), strings.TrimSpace(pr[i+1:])
+		hasValue = true
+	}
+	var value string
+	if hasValue {
+		v, err := parseLiteral(rhs)
+		if err != nil {
+			return nil, err
+		}
+		value = v
+	}
+	rt := make([]Node, 0, len(cands))
+	for _, c := range cands {
+		e, ok := c.(*Ele)
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(lhs, "@") {
+			v, ok := e.GetAttr(parseName(lhs[1:]))
+			if ok && (!hasValue || v == value) {
+				rt = append(rt, c)
+			}
+			continue
+		}
+		for _, ce := range e.Eles(parseName(lhs)) {
+			if !hasValue || ce.Text() == value {
+				rt = append(rt, c)
+				break
+			}
+		}
+	}
+	return rt, nil
+}
+
+func indexOutsideQuotes(s string, b byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == b:
+			return i
+		}
+	}
+	return -1
+}
+
+// parseLiteral parses an xpath string literal, including the concat() xpathLiteral makes
+func parseLiteral(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], nil
+	}
+	if strings.HasPrefix(s, "concat(") && strings.HasSuffix(s, ")") {
+		args := s[len("concat(") : len(s)-1]
+		parts := make([]string, 0, 3)
+		for {
+			i := indexOutsideQuotes(args, ',')
+			if i < 0 {
+				break
+			}
+			parts = append(parts, args[:i])
+			args = args[i+1:]
+		}
+		parts = append(parts, args)
+		buf := make([]string, 0, len(parts))
+		for _, p := range parts {
+			v, err := parseLiteral(p)
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, v)
+		}
+		return strings.Join(buf, ""), nil
+	}
+	return "", errors.New("bad literal " + s)
+}
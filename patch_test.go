@@ -0,0 +1,72 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	d, _ := ParseString(`<beans><bean id="a" class="A"/><bean id="b"/></beans>`)
+	p, _ := ParseString(`<diff>
+    <add sel="beans/bean[@id='a']" pos="after"><bean id="c"/></add>
+    <add sel="/beans/bean[@id='b']" type="@class">B</add>
+    <replace sel="beans/bean[1]/@class">A2</replace>
+    <remove sel="beans/bean[@id='a']"/>
+    <add sel="beans" pos="prepend"><!--x--></add>
+</diff>`)
+	err := ApplyPatch(d, p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rs := `<beans><!--x--><bean id="c"/><bean id="b" class="B"/></beans>`
+	if d.ToString() != rs {
+		t.Error("apply patch failed")
+		t.Error(d.ToString())
+	}
+}
+
+func TestApplyPatchAtomic(t *testing.T) {
+	xs := `<p><a/></p>`
+	d, _ := ParseString(xs)
+	p, _ := ParseString(`<diff><remove sel="p/a"/><remove sel="p/b"/></diff>`)
+	err := ApplyPatch(d, p)
+	if err == nil {
+		t.Error("should fail")
+	}
+	if d.ToString() != xs {
+		t.Error("doc changed by a failed patch")
+	}
+}
+
+func TestMakePatch(t *testing.T) {
+	a, _ := ParseString(`<?xml version="1.0"?>
+<beans x="1">
+    <bean id="a"><property name="url" value="1"/></bean>
+    <!-- old -->
+    <bean id="b">text</bean>
+</beans>`)
+	b, _ := ParseString(`<?xml version="1.0"?>
+<beans y="2">
+    <bean id="a"><property name="url" value="2"/><property name="user"/></bean>
+    <list/>
+    <bean id="b">new text</bean>
+</beans>
+<!-- tail -->`)
+	p := MakePatch(a, b)
+	p, err := ParseString(p.ToString())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = ApplyPatch(a, p)
+	if err != nil {
+		t.Error(err)
+		t.Error(p.ToString())
+		return
+	}
+	if a.ToString() != b.ToString() {
+		t.Error("make patch failed")
+		t.Error(a.ToString())
+		t.Error(p.ToString())
+	}
+}
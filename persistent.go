@@ -0,0 +1,425 @@
+package gdom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"iter"
+	"slices"
+)
+
+// ErrPath is returned by the PDoc edits for a Path that leads nowhere
+var ErrPath = errors.New("path does not lead to a node")
+
+// PNode is a node of a persistent tree, one of *PEle, PCharData, PCDATA,
+// PComment, PProcInst, PDirective and PEntityRef. a persistent node is never changed once made, so
+// trees can share it
+type PNode interface {
+	pnode()
+}
+
+type PCharData string
+
+// PCDATA is the text of a CDATA section
+type PCDATA string
+
+type PComment string
+
+type PDirective string
+
+type PProcInst struct {
+	Target string
+	Inst   string
+}
+
+// PEntityRef is a reference to a named entity, Value is the text it stands for
+type PEntityRef struct {
+	Name  string
+	Value string
+}
+
+func (PCharData) pnode()  {}
+func (PCDATA) pnode()     {}
+func (PComment) pnode()   {}
+func (PDirective) pnode() {}
+func (PProcInst) pnode()  {}
+func (PEntityRef) pnode() {}
+func (*PEle) pnode()      {}
+
+// PEle is the element of a persistent tree
+type PEle struct {
+	name  Name
+	attrs []Attr
+	nodes []PNode
+}
+
+// make a *PEle, attrs and nodes are copied
+func NewPEle(name Name, attrs []Attr, nodes ...PNode) *PEle {
+	return &PEle{
+		name:  name,
+		attrs: slices.Clone(attrs),
+		nodes: slices.Clone(nodes),
+	}
+}
+
+func (e *PEle) Name() Name {
+	return e.name
+}
+
+func (e *PEle) Attr(name Name) (string, bool) {
+	for _, a := range e.attrs {
+		if a.Name == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// return a copy of the attrs of e
+func (e *PEle) Attrs() []Attr {
+	return slices.Clone(e.attrs)
+}
+
+// return the number of child nodes
+func (e *PEle) Len() int {
+	return len(e.nodes)
+}
+
+// return the child node at i, nil if i is out of range
+func (e *PEle) Child(i int) PNode {
+	if i < 0 || i >= len(e.nodes) {
+		return nil
+	}
+	return e.nodes[i]
+}
+
+// the child nodes of e
+func (e *PEle) Children() iter.Seq[PNode] {
+	return slices.Values(e.nodes)
+}
+
+// Path leads from the root element to a node, each entry is the index of a
+// child node. the empty Path is the root element
+type Path []int
+
+// PDoc is a persistent xml doc, each edit returns a new PDoc sharing all the
+// nodes that are not on the path to the edit with d
+type PDoc struct {
+	nodes []PNode
+	root  int
+}
+
+func NewPDoc(root *PEle) *PDoc {
+	return &PDoc{
+		nodes: []PNode{root},
+		root:  0,
+	}
+}
+
+func (d *PDoc) Root() *PEle {
+	return d.nodes[d.root].(*PEle)
+}
+
+// return the node at p
+func (d *PDoc) Get(p Path) (PNode, error) {
+	var n PNode = d.Root()
+	for _, i := range p {
+		e, ok := n.(*PEle)
+		if !ok || e.Child(i) == nil {
+			return nil, ErrPath
+		}
+		n = e.nodes[i]
+	}
+	return n, nil
+}
+
+// edit returns a copy of d in which the element at p is replaced by the one f returns
+func (d *PDoc) edit(p Path, f func(e *PEle) (*PEle, error)) (*PDoc, error) {
+	ne, err := editEle(d.Root(), p, f)
+	if err != nil {
+		return nil, err
+	}
+	nd := &PDoc{
+		nodes: slices.Clone(d.nodes),
+		root:  d.root,
+	}
+	nd.nodes[d.root] = ne
+	return nd, nil
+}
+
+// editEle copies the elements from e down to the element at p, which is replaced
+// by the one f returns
+func editEle(e *PEle, p Path, f func(e *PEle) (*PEle, error)) (*PEle, error) {
+	if len(p) == 0 {
+		return f(e)
+	}
+	c, ok := e.Child(p[0]).(*PEle)
+	if !ok {
+		return nil, ErrPath
+	}
+	nc, err := editEle(c, p[1:], f)
+	if err != nil {
+		return nil, err
+	}
+	cp := *e
+	cp.nodes = slices.Clone(e.nodes)
+	cp.nodes[p[0]] = nc
+	return &cp, nil
+}
+
+// SetAttr sets the attr name of the element at p to value
+func (d *PDoc) SetAttr(p Path, name Name, value string) (*PDoc, error) {
+	return d.edit(p, func(e *PEle) (*PEle, error) {
+		cp := *e
+		cp.attrs = slices.Clone(e.attrs)
+		for i, a := range cp.attrs {
+			if a.Name == name {
+				cp.attrs[i].Value = value
+				return &cp, nil
+			}
+		}
+		cp.attrs = append(cp.attrs, Attr{Name: name, Value: value})
+		return &cp, nil
+	})
+}
+
+// RemoveAttr removes the attr name of the element at p
+func (d *PDoc) RemoveAttr(p Path, name Name) (*PDoc, error) {
+	return d.edit(p, func(e *PEle) (*PEle, error) {
+		cp := *e
+		cp.attrs = slices.DeleteFunc(slices.Clone(e.attrs), func(a Attr) bool {
+			return a.Name == name
+		})
+		return &cp, nil
+	})
+}
+
+// AddEle adds c as the last child of the element at p
+func (d *PDoc) AddEle(p Path, c *PEle) (*PDoc, error) {
+	return d.AddNode(p, c)
+}
+
+// AddNode adds n as the last child of the element at p
+func (d *PDoc) AddNode(p Path, n PNode) (*PDoc, error) {
+	return d.edit(p, func(e *PEle) (*PEle, error) {
+		return e.insert(len(e.nodes), n), nil
+	})
+}
+
+// InsertNode inserts n as the child i of the element at p
+func (d *PDoc) InsertNode(p Path, i int, n PNode) (*PDoc, error) {
+	return d.edit(p, func(e *PEle) (*PEle, error) {
+		if i < 0 || i > len(e.nodes) {
+			return nil, ErrPath
+		}
+		return e.insert(i, n), nil
+	})
+}
+
+func (e *PEle) insert(i int, n PNode) *PEle {
+	cp := *e
+	cp.nodes = slices.Insert(slices.Clip(e.nodes), i, n)
+	return &cp
+}
+
+// RemoveNode removes the node at p, which can't be the root
+func (d *PDoc) RemoveNode(p Path) (*PDoc, error) {
+	if len(p) == 0 {
+		return nil, ErrRoot
+	}
+	i := p[len(p)-1]
+	return d.edit(p[:len(p)-1], func(e *PEle) (*PEle, error) {
+		if e.Child(i) == nil {
+			return nil, ErrPath
+		}
+		cp := *e
+		cp.nodes = slices.Delete(slices.Clone(e.nodes), i, i+1)
+		return &cp, nil
+	})
+}
+
+// ReplaceNode puts n in the place of the node at p, the root can only be
+// replaced by a *PEle
+func (d *PDoc) ReplaceNode(p Path, n PNode) (*PDoc, error) {
+	if len(p) == 0 {
+		e, ok := n.(*PEle)
+		if !ok {
+			return nil, ErrRoot
+		}
+		return d.SetRoot(e), nil
+	}
+	i := p[len(p)-1]
+	return d.edit(p[:len(p)-1], func(e *PEle) (*PEle, error) {
+		if e.Child(i) == nil {
+			return nil, ErrPath
+		}
+		cp := *e
+		cp.nodes = slices.Clone(e.nodes)
+		cp.nodes[i] = n
+		return &cp, nil
+	})
+}
+
+// SetRoot returns a copy of d with the root element e
+func (d *PDoc) SetRoot(e *PEle) *PDoc {
+	nd := &PDoc{
+		nodes: slices.Clone(d.nodes),
+		root:  d.root,
+	}
+	nd.nodes[d.root] = e
+	return nd
+}
+
+// Doc makes a mutable Doc of d. it only makes the top level nodes, the children
+// of an element are made the first time they are asked for
+func (d *PDoc) Doc() *Doc {
+	doc := &Doc{
+		nodes: make(nodeList, 0, len(d.nodes)),
+	}
+	for i, n := range d.nodes {
+		m := mutableOf(n)
+		if pd, ok := n.(PDirective); ok {
+			m = directiveNode(string(pd))
+		}
+		m.setParent(doc)
+		doc.nodes.PushBack(m)
+		if i == d.root {
+			doc.root = m.(*Ele)
+		}
+	}
+	return doc
+}
+
+func (d *PDoc) Write(w io.Writer) error {
+	for _, n := range d.nodes {
+		err := mutableOf(n).Write(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *PDoc) ToString() string {
+	buf := bytes.NewBuffer(make([]byte, 0, 256))
+	d.Write(buf)
+	return buf.String()
+}
+
+// mutableOf makes the mutable node of n, the children of a *PEle are left lazy
+func mutableOf(n PNode) Node {
+	switch t := n.(type) {
+	case *PEle:
+		e := NewEle(t.name, nil)
+		e.attrs = make([]*Attr, 0, len(t.attrs))
+		for _, a := range t.attrs {
+			e.SetAttr(NewAttr(a.Name, a.Value))
+		}
+		if len(t.nodes) > 0 {
+			e.lazy = pLazy{t}
+		}
+		return e
+	case PCharData:
+		return NewCharData(string(t))
+	case PCDATA:
+		return NewCDATA(string(t))
+	case PComment:
+		return NewComment(string(t))
+	case PProcInst:
+		return NewProcInst(t.Target, t.Inst)
+	case PDirective:
+		return NewDirective(string(t))
+	case PEntityRef:
+		return NewEntityRef(t.Name, t.Value)
+	}
+	return nil
+}
+
+// pLazy holds the children of an element made by PDoc.Doc
+type pLazy struct {
+	e *PEle
+}
+
+func (l pLazy) load(e *Ele) error {
+	for _, n := range l.e.nodes {
+		switch m := mutableOf(n).(type) {
+		case *Ele:
+			addEle(e, m)
+		case *CharData:
+			addCharData(e, m)
+		case *Comment:
+			addComment(e, m)
+		case *ProcInst:
+			addProcInst(e, m)
+		case *Directive:
+			addDirective(e, m)
+		case *EntityRef:
+			addEntityRef(e, m)
+		}
+	}
+	return nil
+}
+
+func (l pLazy) write(w io.Writer) error {
+	for _, n := range l.e.nodes {
+		err := mutableOf(n).Write(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Persistent makes a PDoc of d. an element of a doc made by PDoc.Doc whose
+// children were never asked for shares them with the PDoc it was made of
+func (d *Doc) Persistent() *PDoc {
+	pd := &PDoc{
+		nodes: make([]PNode, 0, len(d.nodes)),
+	}
+	for _, n := range d.nodes {
+		if n == Node(d.root) {
+			pd.root = len(pd.nodes)
+		}
+		pd.nodes = append(pd.nodes, persistentOf(n))
+	}
+	return pd
+}
+
+func persistentOf(n Node) PNode {
+	switch t := n.(type) {
+	case *Ele:
+		pe := &PEle{
+			name:  t.Name,
+			attrs: make([]Attr, 0, len(t.attrs)),
+		}
+		for _, a := range t.attrs {
+			pe.attrs = append(pe.attrs, *a)
+		}
+		if pl, ok := t.lazy.(pLazy); ok {
+			pe.nodes = pl.e.nodes
+			return pe
+		}
+		nodes := t.getNodes()
+		pe.nodes = make([]PNode, 0, nodes.Len())
+		for _, c := range *nodes {
+			pe.nodes = append(pe.nodes, persistentOf(c))
+		}
+		return pe
+	case *CharData:
+		if t.IsCDATA {
+			return PCDATA(t.V)
+		}
+		return PCharData(t.V)
+	case *Comment:
+		return PComment(t.V)
+	case *ProcInst:
+		return PProcInst{Target: t.Target, Inst: t.Inst}
+	case *Directive:
+		return PDirective(t.V)
+	case *DocType:
+		return PDirective(t.directive())
+	case *EntityRef:
+		return PEntityRef{Name: t.Name, Value: t.Value}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package gdom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPersistent(t *testing.T) {
+	d, _ := ParseString(`<beans><bean id="a"><property name="x"/></bean><bean id="b"><list/></bean></beans>`)
+	p0 := d.Persistent()
+	p1, err := p0.SetAttr(Path{0}, NewName("", "id"), "c")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p2, err := p1.AddEle(Path{1, 0}, NewPEle(NewName("", "value"), nil, PCharData("1")))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p3, err := p2.RemoveNode(Path{0, 0})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if p0.ToString() != d.ToString() {
+		t.Error("old version changed", p0.ToString())
+	}
+	if p1.ToString() != `<beans><bean id="c"><property name="x"/></bean><bean id="b"><list/></bean></beans>` {
+		t.Error("set attr failed", p1.ToString())
+	}
+	if p3.ToString() != `<beans><bean id="c"/><bean id="b"><list><value>1</value></list></bean></beans>` {
+		t.Error("edits failed", p3.ToString())
+	}
+	if p1.Root().Child(1) != p0.Root().Child(1) || p2.Root().Child(0) != p1.Root().Child(0) {
+		t.Error("untouched subtrees not shared")
+	}
+	if _, err := p0.SetAttr(Path{5}, NewName("", "x"), ""); !errors.Is(err, ErrPath) {
+		t.Error("bad path not rejected", err)
+	}
+
+	m := p2.Doc()
+	if m.Root().lazy == nil || m.ToString() != p2.ToString() {
+		t.Error("doc of a pdoc differs", m.ToString())
+	}
+	m.Root().AllEles()[0].SetAttr(NewAttr(NewName("", "id"), "d"))
+	p4 := m.Persistent()
+	if p4.Root().Child(1).(*PEle).Child(0) != p2.Root().Child(1).(*PEle).Child(0) {
+		t.Error("lazy children not shared on the way back")
+	}
+	if v, _ := p4.Root().Child(0).(*PEle).Attr(NewName("", "id")); v != "d" {
+		t.Error("change not kept", v)
+	}
+	if err := m.Check(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,379 @@
+package gdom
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Source looks up the values of placeholders
+type Source interface {
+	Lookup(key string) (string, bool)
+	// String names the source in a Resolution
+	String() string
+}
+
+// MapSource is a Source backed by a map
+type MapSource map[string]string
+
+func (m MapSource) Lookup(key string) (string, bool) {
+	v, ok := m[key]
+	return v, ok
+}
+
+func (m MapSource) String() string {
+	return "map"
+}
+
+// EnvSource looks up the environment, a key like db.url is also tried as DB_URL
+type EnvSource struct{}
+
+func (EnvSource) Lookup(key string) (string, bool) {
+	v, ok := os.LookupEnv(key)
+	if ok {
+		return v, true
+	}
+	return os.LookupEnv(strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key)))
+}
+
+func (EnvSource) String() string {
+	return "env"
+}
+
+// PropertiesSource holds the entries of a .properties file
+type PropertiesSource struct {
+	Name  string
+	Props map[string]string
+}
+
+func (p *PropertiesSource) Lookup(key string) (string, bool) {
+	v, ok := p.Props[key]
+	return v, ok
+}
+
+func (p *PropertiesSource) String() string {
+	return p.Name
+}
+
+// LoadProperties reads the .properties file path
+func LoadProperties(path string) (*PropertiesSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseProperties(path, f)
+}
+
+// ParseProperties reads properties in the java .properties format from r
+func ParseProperties(name string, r io.Reader) (*PropertiesSource, error) {
+	p := &PropertiesSource{
+		Name:  name,
+		Props: make(map[string]string),
+	}
+	sc := bufio.NewScanner(r)
+	line := ""
+	for sc.Scan() {
+		s := strings.TrimLeft(sc.Text(), " \t\f")
+		if line == "" && (s == "" || s[0] == '#' || s[0] == '!') {
+			continue
+		}
+		// an odd number of trailing backslashes continues the line
+		n := len(s) - len(strings.TrimRight(s, "\\"))
+		if n%2 == 1 {
+			line += s[:len(s)-1]
+			continue
+		}
+		line += s
+		k, v := splitProperty(line)
+		p.Props[unescapeProperty(k)] = unescapeProperty(v)
+		line = ""
+	}
+	if line != "" {
+		k, v := splitProperty(line)
+		p.Props[unescapeProperty(k)] = unescapeProperty(v)
+	}
+	return p, sc.Err()
+}
+
+// splitProperty splits a logical line at the first unescaped '=', ':' or whitespace
+func splitProperty(line string) (string, string) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':':
+			return line[:i], strings.TrimLeft(line[i+1:], " \t\f")
+		case ' ', '\t', '\f':
+			v := strings.TrimLeft(line[i:], " \t\f")
+			if v != "" && (v[0] == '=' || v[0] == ':') {
+				v = strings.TrimLeft(v[1:], " \t\f")
+			}
+			return line[:i], v
+		}
+	}
+	return line, ""
+}
+
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	buf := make([]rune, 0, len(s))
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		if rs[i] != '\\' || i+1 == len(rs) {
+			buf = append(buf, rs[i])
+			continue
+		}
+		i++
+		switch rs[i] {
+		case 't':
+			buf = append(buf, '\t')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'u':
+			if i+4 < len(rs) {
+				v, err := strconv.ParseUint(string(rs[i+1:i+5]), 16, 32)
+				if err == nil {
+					buf = append(buf, rune(v))
+					i += 4
+					continue
+				}
+			}
+			buf = append(buf, 'u')
+		default:
+			buf = append(buf, rs[i])
+		}
+	}
+	return string(buf)
+}
+
+// XMLSource looks up values in a Doc, the key db.url is the text of
+// <db><url>...</url></db> below the root, or the url attr of <db url="..."/>
+type XMLSource struct {
+	Name string
+	Doc  *Doc
+}
+
+func (x *XMLSource) Lookup(key string) (string, bool) {
+	e := x.Doc.Root()
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		name := parseName(p)
+		if i == len(parts)-1 {
+			if v, ok := e.GetAttr(name); ok {
+				return v, true
+			}
+		}
+		es := e.Eles(name)
+		if len(es) == 0 {
+			return "", false
+		}
+		e = es[0]
+	}
+	return e.Text(), true
+}
+
+func (x *XMLSource) String() string {
+	if x.Name == "" {
+		return "xml"
+	}
+	return x.Name
+}
+
+// Resolution records the value a placeholder got and where it came from
+type Resolution struct {
+	Key   string
+	Value string
+	// the String() of the Source, or "default"
+	Source string
+	// the *Ele holding the attr, or the *CharData
+	Node Node
+	Attr Name
+}
+
+// Resolver resolves ${key} and ${key:default} placeholders. keys, defaults and
+// values may contain placeholders themselves
+type Resolver struct {
+	// the sources are asked in order, the first one knowing a key wins
+	Sources []Source
+	// leave placeholders that can't be resolved as they are instead of failing
+	IgnoreUnresolvable bool
+	// collect a Resolution for every placeholder resolved
+	Record bool
+}
+
+// ResolvePlaceholders substitutes the placeholders in all attr values and CharData of d.
+// the doc is only changed if every placeholder could be resolved
+func (d *Doc) ResolvePlaceholders(r *Resolver) ([]Resolution, error) {
+	if err := frozenErr("ResolvePlaceholders", nil, d); err != nil {
+		return nil, err
+	}
+	ps := &placeholders{r: r}
+	type update struct {
+		ele  *Ele
+		attr Name
+		cd   *CharData
+		v    string
+	}
+	updates := make([]update, 0, 16)
+	var walk func(e *Ele) error
+	walk = func(e *Ele) error {
+		for _, a := range e.allAttrs() {
+			ps.node, ps.attr = e, a.Name
+			v, err := ps.resolve(a.Value, nil)
+			if err != nil {
+				return err
+			}
+			if v != a.Value {
+				updates = append(updates, update{ele: e, attr: a.Name, v: v})
+			}
+		}
+		for _, n := range e.AllNodes() {
+			switch nd := n.(type) {
+			case *Ele:
+				err := walk(nd)
+				if err != nil {
+					return err
+				}
+			case *CharData:
+				ps.node, ps.attr = nd, Name{}
+				v, err := ps.resolve(nd.V, nil)
+				if err != nil {
+					return err
+				}
+				if v != nd.V {
+					updates = append(updates, update{cd: nd, v: v})
+				}
+			}
+		}
+		return nil
+	}
+	err := walk(d.Root())
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range updates {
+		if u.cd != nil {
+			setText(u.cd, u.v)
+		} else {
+			u.ele.SetAttr(NewAttr(u.attr, u.v))
+		}
+	}
+	return ps.record, nil
+}
+
+type placeholders struct {
+	r      *Resolver
+	node   Node
+	attr   Name
+	record []Resolution
+}
+
+// resolve substitutes the placeholders in s, stack holds the keys being resolved
+func (ps *placeholders) resolve(s string, stack []string) (string, error) {
+	i := strings.Index(s, "${")
+	if i < 0 {
+		return s, nil
+	}
+	buf := make([]string, 0, 4)
+	for i >= 0 {
+		end := placeholderEnd(s, i+2)
+		if end < 0 {
+			break
+		}
+		buf = append(buf, s[:i])
+		v, err := ps.value(s[i+2:end], s[i:end+1], stack)
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, v)
+		s = s[end+1:]
+		i = strings.Index(s, "${")
+	}
+	buf = append(buf, s)
+	return strings.Join(buf, ""), nil
+}
+
+// placeholderEnd returns the index of the '}' closing the placeholder whose key starts at from
+func placeholderEnd(s string, from int) int {
+	depth := 1
+	for i := from; i < len(s); i++ {
+		if s[i] == '$' && i+1 < len(s) && s[i+1] == '{' {
+			depth++
+			i++
+		} else if s[i] == '}' {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// value resolves the placeholder with the content inner, raw is the whole placeholder
+func (ps *placeholders) value(inner, raw string, stack []string) (string, error) {
+	key, def, hasDef := inner, "", false
+	depth := 0
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '$' && i+1 < len(inner) && inner[i+1] == '{' {
+			depth++
+			i++
+		} else if inner[i] == '}' {
+			depth--
+		} else if inner[i] == ':' && depth == 0 {
+			key, def, hasDef = inner[:i], inner[i+1:], true
+			break
+		}
+	}
+	key, err := ps.resolve(key, stack)
+	if err != nil {
+		return "", err
+	}
+	for _, k := range stack {
+		if k == key {
+			return "", errors.New("placeholder cycle: " + strings.Join(append(stack, key), " -> "))
+		}
+	}
+	stack = append(stack, key)
+	for _, src := range ps.r.Sources {
+		v, ok := src.Lookup(key)
+		if !ok {
+			continue
+		}
+		v, err = ps.resolve(v, stack)
+		if err != nil {
+			return "", err
+		}
+		ps.add(key, v, src.String())
+		return v, nil
+	}
+	if hasDef {
+		v, err := ps.resolve(def, stack)
+		if err != nil {
+			return "", err
+		}
+		ps.add(key, v, "default")
+		return v, nil
+	}
+	if ps.r.IgnoreUnresolvable {
+		return raw, nil
+	}
+	return "", errors.New("can't resolve placeholder " + raw)
+}
+
+func (ps *placeholders) add(key, value, source string) {
+	if !ps.r.Record {
+		return
+	}
+	ps.record = append(ps.record, Resolution{Key: key, Value: value, Source: source, Node: ps.node, Attr: ps.attr})
+}
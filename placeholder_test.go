@@ -0,0 +1,78 @@
+package gdom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolvePlaceholders(t *testing.T) {
+	d, _ := ParseString(`<bean id="ds">
+    <property name="url" value="${db.url}"/>
+    <property name="username" value="${db.user:scott}"/>
+    <property name="password" value="${db.${env}.password}"/>
+    <description>pool of ${db.pool:${db.user:scott}}</description>
+</bean>`)
+	props, err := ParseProperties("db.properties", strings.NewReader(`# database
+db.url = jdbc:oracle:thin:@${db.host}:1521
+db.host:localhost
+db.prod.password=\
+    secret`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf, _ := ParseString(`<conf><env>prod</env></conf>`)
+	rs, err := d.ResolvePlaceholders(&Resolver{
+		Sources: []Source{props, &XMLSource{Doc: conf}},
+		Record:  true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rss := `<bean id="ds">
+    <property name="url" value="jdbc:oracle:thin:@localhost:1521"/>
+    <property name="username" value="scott"/>
+    <property name="password" value="secret"/>
+    <description>pool of scott</description>
+</bean>`
+	if d.ToString() != rss {
+		t.Error("resolve failed")
+		t.Error(d.ToString())
+	}
+	if len(rs) != 7 || rs[0].Key != "db.host" || rs[0].Source != "db.properties" {
+		t.Error("wrong resolutions", rs)
+	}
+	v, _ := d.Root().AllEles()[0].GetAttrByStrName("", "value")
+	if v != "jdbc:oracle:thin:@localhost:1521" {
+		t.Error("attr map not updated")
+	}
+}
+
+func TestResolvePlaceholdersCycle(t *testing.T) {
+	xs := `<p a="${x}"/>`
+	d, _ := ParseString(xs)
+	_, err := d.ResolvePlaceholders(&Resolver{Sources: []Source{MapSource{"x": "${y}", "y": "${x}"}}})
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Error("cycle not detected", err)
+	}
+	if d.ToString() != xs {
+		t.Error("doc changed by a failed resolve")
+	}
+}
+
+func TestResolvePlaceholdersEscape(t *testing.T) {
+	d, _ := ParseString(`<r a="${x}">${x}</r>`)
+	_, err := d.ResolvePlaceholders(&Resolver{Sources: []Source{MapSource{"x": `a"<&b`}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := d.ToString()
+	if s != `<r a="a&#34;&lt;&amp;b">a&#34;&lt;&amp;b</r>` {
+		t.Error("not escaped:", s)
+	}
+	d, err = ParseString(s)
+	if v, _ := d.Root().GetAttrByStrName("", "a"); err != nil || v != `a"<&b` {
+		t.Error("reparse:", v, err)
+	}
+}
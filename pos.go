@@ -0,0 +1,221 @@
+package gdom
+
+import (
+	"io"
+	"strconv"
+)
+
+// Position is a place in the source of a parsed doc. Line and Column start at 1,
+// Column counts bytes like xml.Decoder.InputPos, Offset is the byte offset
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// IsValid reports whether p is a place in a source, nodes not made by Parse with
+// ParseOptions.Positions have no position
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// return line:column, or - if p is not valid
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+// Span is where a node or an attr is in the source, End is just after it.
+// the span of an *Ele runs from its start tag to the end of its end tag
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func spanOf(s *Span) Span {
+	if s == nil {
+		return Span{}
+	}
+	return *s
+}
+
+func (e *Ele) Pos() Span {
+	return spanOf(e.pos)
+}
+
+func (c *CharData) Pos() Span {
+	return spanOf(c.pos)
+}
+
+func (c *Comment) Pos() Span {
+	return spanOf(c.pos)
+}
+
+func (p *ProcInst) Pos() Span {
+	return spanOf(p.pos)
+}
+
+func (d *Directive) Pos() Span {
+	return spanOf(d.pos)
+}
+
+// return the span of the attr from its name to the closing quote of its value
+func (a *Attr) Pos() Span {
+	return spanOf(a.pos)
+}
+
+// return the name of the file d was parsed from, see ParseOptions.FileName
+func (d *Doc) FileName() string {
+	return d.file
+}
+
+func (d *Doc) SetFileName(name string) {
+	d.file = name
+}
+
+// Location returns p as file:line:column, like beans.xml:42:9, the file is left
+// out if d has no file name
+func (d *Doc) Location(p Position) string {
+	if d.file == "" {
+		return p.String()
+	}
+	return d.file + ":" + p.String()
+}
+
+// spanSlab hands out the spans of a parse from a few large slices, a nil
+// *spanSlab hands out none
+type spanSlab []Span
+
+func (s *spanSlab) new(sp Span) *Span {
+	if s == nil {
+		return nil
+	}
+	if len(*s) == cap(*s) {
+		*s = make([]Span, 0, 256)
+	}
+	*s = append(*s, sp)
+	return &(*s)[len(*s)-1]
+}
+
+// tapReader is the buffered reader of the decoder, it keeps the bytes not
+// dropped yet so that the attrs in a start tag can be found
+type tapReader struct {
+	r   io.Reader
+	buf []byte
+	// the next byte to read is buf[pos], the bytes before keep are dropped
+	pos  int
+	keep int
+	// the offset of buf[0] in the source
+	off int
+	err error
+}
+
+func (t *tapReader) ReadByte() (byte, error) {
+	if t.pos == len(t.buf) {
+		t.fill()
+		if t.pos == len(t.buf) {
+			return 0, t.err
+		}
+	}
+	b := t.buf[t.pos]
+	t.pos++
+	return b, nil
+}
+
+// the decoder only reads byte by byte, Read is there for the io.Reader it wants
+func (t *tapReader) Read(p []byte) (int, error) {
+	for i := range p {
+		b, err := t.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		p[i] = b
+	}
+	return len(p), nil
+}
+
+func (t *tapReader) fill() {
+	if t.err != nil {
+		return
+	}
+	if t.keep > 0 {
+		n := copy(t.buf, t.buf[t.keep:])
+		t.buf = t.buf[:n]
+		t.pos -= t.keep
+		t.off += t.keep
+		t.keep = 0
+	}
+	if cap(t.buf)-len(t.buf) < 512 {
+		nb := make([]byte, len(t.buf), max(4096, 2*cap(t.buf)))
+		copy(nb, t.buf)
+		t.buf = nb
+	}
+	n, err := t.r.Read(t.buf[len(t.buf):cap(t.buf)])
+	t.buf = t.buf[:len(t.buf)+n]
+	if n == 0 && err == nil {
+		err = io.ErrNoProgress
+	}
+	if n == 0 {
+		t.err = err
+	}
+}
+
+// return the bytes from the source offset from to the offset to
+func (t *tapReader) bytes(from, to int) []byte {
+	return t.buf[from-t.off : to-t.off]
+}
+
+// drop the bytes before the source offset to
+func (t *tapReader) drop(to int) {
+	t.keep = to - t.off
+}
+
+// advance moves p over b
+func advance(p Position, b []byte) Position {
+	for _, c := range b {
+		p.Offset++
+		if c == '\n' {
+			p.Line++
+			p.Column = 1
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
+
+// attrSpans sets the spans of the attrs of the start tag tag, which starts at
+// start, the attrs are in the order of the tag
+func attrSpans(tag []byte, start Position, attrs []*Attr, slab *spanSlab) {
+	i := 1
+	for i < len(tag) && !isTagSpace(tag[i]) && tag[i] != '/' && tag[i] != '>' {
+		i++
+	}
+	p := advance(start, tag[:i])
+	for _, a := range attrs {
+		j := i
+		for j < len(tag) && isTagSpace(tag[j]) {
+			j++
+		}
+		p = advance(p, tag[i:j])
+		i = j
+		var quote byte
+		for ; j < len(tag); j++ {
+			if quote == 0 && (tag[j] == '"' || tag[j] == '\'') {
+				quote = tag[j]
+			} else if quote != 0 && tag[j] == quote {
+				j++
+				break
+			}
+		}
+		end := advance(p, tag[i:j])
+		a.pos = slab.new(Span{Start: p, End: end})
+		p, i = end, j
+	}
+}
+
+func isTagSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,52 @@
+package gdom
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPos(t *testing.T) {
+	src := "<?xml version=\"1.0\"?>\n<beans>\n  <bean id=\"a\"\n        class='A'>text<![CDATA[x]]></bean>\n  <!--c--><b/>\n</beans>"
+	d, err := ParseWithOptions(strings.NewReader(src), &ParseOptions{FileName: "beans.xml", Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := d.Root()
+	if p := r.Pos(); p.Start != (Position{2, 1, strings.Index(src, "<beans>")}) || p.End.Offset != len(src) {
+		t.Error("root:", p)
+	}
+	bean := r.AllEles()[0]
+	if p := bean.Pos(); p.Start != (Position{3, 3, strings.Index(src, "<bean ")}) || src[p.Start.Offset:p.End.Offset] != "<bean id=\"a\"\n        class='A'>text<![CDATA[x]]></bean>" {
+		t.Error("bean:", p)
+	}
+	class := bean.GetAttrNode(NewName("", "class"))
+	if p := class.Pos(); d.Location(p.Start) != "beans.xml:4:9" || src[p.Start.Offset:p.End.Offset] != "class='A'" {
+		t.Error("attr:", p)
+	}
+	if p := bean.GetAttrNode(NewName("", "id")).Pos(); src[p.Start.Offset:p.End.Offset] != `id="a"` {
+		t.Error("attr:", p)
+	}
+	if p := bean.FirstChild().Pos(); src[p.Start.Offset:p.End.Offset] != "text" {
+		t.Error("text:", p)
+	}
+	if p := bean.LastChild().Pos(); src[p.Start.Offset:p.End.Offset] != "<![CDATA[x]]>" {
+		t.Error("cdata:", p)
+	}
+	b := r.AllEles()[1]
+	if p := b.PrevSibling().Pos(); p.Start != (Position{5, 3, strings.Index(src, "<!--")}) {
+		t.Error("comment:", p)
+	}
+	if p := b.Pos(); src[p.Start.Offset:p.End.Offset] != "<b/>" {
+		t.Error("empty:", p)
+	}
+	if d.Copy().Root().AllEles()[1].Pos() != b.Pos() {
+		t.Error("copy lost the position")
+	}
+	if NewEle(NewName("", "n"), nil).Pos().Start.IsValid() {
+		t.Error("new node has a position")
+	}
+	plain, _ := ParseString(src)
+	if plain.Root().Pos().Start.IsValid() || plain.Root().AllEles()[0].GetAttrNode(NewName("", "id")).Pos().Start.IsValid() {
+		t.Error("positions kept without ParseOptions.Positions")
+	}
+}
@@ -0,0 +1,148 @@
+package gdom
+
+import "strings"
+
+// Declaration is the <?xml ...?> declaration in front of a doc
+type Declaration struct {
+	Version    string
+	Encoding   string
+	Standalone string
+}
+
+// return the declaration of d, false if d does not start with one
+func (d *Doc) Declaration() (Declaration, bool) {
+	pi, ok := d.nodes.Front().(*ProcInst)
+	if !ok || pi.Target != "xml" {
+		return Declaration{}, false
+	}
+	var decl Declaration
+	decl.Version, _ = pi.PseudoAttr("version")
+	decl.Encoding, _ = pi.PseudoAttr("encoding")
+	decl.Standalone, _ = pi.PseudoAttr("standalone")
+	return decl, true
+}
+
+// SetDeclaration puts decl in the place of the declaration of d, or in front of
+// everything if d has none. an empty Version is written as 1.0
+func (d *Doc) SetDeclaration(decl Declaration) error {
+	if err := frozenErr("SetDeclaration", nil, d); err != nil {
+		return err
+	}
+	if _, ok := d.Declaration(); ok {
+		removeAt(d, 0)
+	}
+	insertNode(d, 0, decl.ProcInst())
+	return nil
+}
+
+// RemoveDeclaration removes the declaration of d, if it has one
+func (d *Doc) RemoveDeclaration() error {
+	if err := frozenErr("RemoveDeclaration", nil, d); err != nil {
+		return err
+	}
+	if _, ok := d.Declaration(); ok {
+		removeAt(d, 0)
+	}
+	return nil
+}
+
+// make the <?xml ...?> *ProcInst of decl
+func (decl Declaration) ProcInst() *ProcInst {
+	v := decl.Version
+	if v == "" {
+		v = "1.0"
+	}
+	attrs := []Attr{{Name: Name{Local: "version"}, Value: v}}
+	if decl.Encoding != "" {
+		attrs = append(attrs, Attr{Name: Name{Local: "encoding"}, Value: decl.Encoding})
+	}
+	if decl.Standalone != "" {
+		attrs = append(attrs, Attr{Name: Name{Local: "standalone"}, Value: decl.Standalone})
+	}
+	return NewProcInstAttrs("xml", attrs...)
+}
+
+// make a *ProcInst whose Inst holds attrs as pseudo attrs, like
+// <?xml-stylesheet href="a.xsl" type="text/xsl"?>
+func NewProcInstAttrs(target string, attrs ...Attr) *ProcInst {
+	var sb strings.Builder
+	for i, a := range attrs {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(nameString(a.Name) + "=")
+		v := strings.NewReplacer("&", "&amp;", "<", "&lt;").Replace(a.Value)
+		if strings.Contains(v, `"`) {
+			sb.WriteString("'" + strings.ReplaceAll(v, "'", "&apos;") + "'")
+		} else {
+			sb.WriteString(`"` + v + `"`)
+		}
+	}
+	return NewProcInst(target, sb.String())
+}
+
+// PseudoAttrs parses the Inst of p as name="value" pairs, it stops at the first
+// thing that is not one
+func (p *ProcInst) PseudoAttrs() []Attr {
+	var attrs []Attr
+	rest := trimSpace(p.Inst)
+	for rest != "" {
+		i := strings.IndexByte(rest, '=')
+		if i <= 0 {
+			break
+		}
+		name := strings.TrimRight(rest[:i], " \t\r\n")
+		if strings.ContainsAny(name, " \t\r\n\"'") {
+			break
+		}
+		v, r, ok := quoted(rest[i+1:])
+		if !ok {
+			break
+		}
+		attrs = append(attrs, Attr{Name: splitName(name), Value: textOf([]byte(v))})
+		rest = r
+	}
+	return attrs
+}
+
+// return the value of the pseudo attr name of p
+func (p *ProcInst) PseudoAttr(name string) (string, bool) {
+	for _, a := range p.PseudoAttrs() {
+		if nameString(a.Name) == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// return the nodes of d in front of the root
+func (d *Doc) Prolog() []Node {
+	if d.root == nil {
+		return nil
+	}
+	return append([]Node(nil), d.nodes[:d.root.index()]...)
+}
+
+// return the nodes of d after the root
+func (d *Doc) Epilog() []Node {
+	if d.root == nil {
+		return nil
+	}
+	return append([]Node(nil), d.nodes[d.root.index()+1:]...)
+}
+
+// AddProlog adds a copy of n as the last node in front of the root, n can't be a *Ele
+func (d *Doc) AddProlog(n Node) error {
+	if d.root == nil {
+		return treeErr("AddProlog", n, ErrRoot)
+	}
+	return d.InsertBefore(n, d.root)
+}
+
+// AddEpilog adds a copy of n as the last node of d, n can't be a *Ele
+func (d *Doc) AddEpilog(n Node) error {
+	if d.root == nil {
+		return treeErr("AddEpilog", n, ErrRoot)
+	}
+	return d.InsertAfter(n, d.nodes.Back())
+}
@@ -0,0 +1,56 @@
+package gdom
+
+import (
+	"testing"
+)
+
+func TestDeclaration(t *testing.T) {
+	d, _ := ParseString(`<?xml version="1.0" encoding='UTF-8' standalone="yes"?><a/>`)
+	decl, ok := d.Declaration()
+	if !ok || decl != (Declaration{Version: "1.0", Encoding: "UTF-8", Standalone: "yes"}) {
+		t.Error("declaration:", decl)
+	}
+	d.SetDeclaration(Declaration{Encoding: "ISO-8859-1"})
+	if d.ToString() != `<?xml version="1.0" encoding="ISO-8859-1"?><a/>` {
+		t.Error("set:", d.ToString())
+	}
+	d.RemoveDeclaration()
+	if _, ok := d.Declaration(); ok || d.ToString() != `<a/>` {
+		t.Error("remove:", d.ToString())
+	}
+	d.SetDeclaration(Declaration{})
+	if d.ToString() != `<?xml version="1.0"?><a/>` {
+		t.Error("add:", d.ToString())
+	}
+}
+
+func TestPseudoAttrs(t *testing.T) {
+	pi := NewProcInst("xml-stylesheet", `href="a.xsl?x=1&amp;y=2"  type = 'text/xsl' alternate`)
+	attrs := pi.PseudoAttrs()
+	if len(attrs) != 2 || attrs[0].Value != "a.xsl?x=1&y=2" || attrs[1].Name.Local != "type" {
+		t.Error("attrs:", attrs)
+	}
+	if v, ok := pi.PseudoAttr("type"); !ok || v != "text/xsl" {
+		t.Error("type:", v)
+	}
+	mk := NewProcInstAttrs("xml-stylesheet", attrs...)
+	if mk.Inst != `href="a.xsl?x=1&amp;y=2" type="text/xsl"` {
+		t.Error("make:", mk.Inst)
+	}
+}
+
+func TestPrologEpilog(t *testing.T) {
+	d, _ := ParseString(`<?xml version="1.0"?><!--a--><r/><!--z-->`)
+	if len(d.Prolog()) != 2 || len(d.Epilog()) != 1 {
+		t.Error("prolog:", d.Prolog(), "epilog:", d.Epilog())
+	}
+	d.AddProlog(NewProcInstAttrs("xml-stylesheet", Attr{Name: Name{Local: "href"}, Value: "s.xsl"}))
+	d.AddEpilog(NewComment("end"))
+	if d.AddProlog(NewEle(NewName("", "x"), nil)) == nil {
+		t.Error("element added to the prolog")
+	}
+	want := `<?xml version="1.0"?><!--a--><?xml-stylesheet href="s.xsl"?><r/><!--z--><!--end-->`
+	if d.ToString() != want {
+		t.Error("add:", d.ToString())
+	}
+}
@@ -0,0 +1,361 @@
+package gdom
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	ErrTxOpen   = errors.New("a transaction is already open")
+	ErrTxDone   = errors.New("transaction is already committed or rolled back")
+	ErrNoChange = errors.New("nothing to undo or redo")
+)
+
+type changeKind int
+
+const (
+	childAdded changeKind = iota
+	childRemoved
+	textChanged
+	attrAdded
+	attrReplaced
+	attrRemoved
+	rootReplaced
+)
+
+// change is one step of a mutation, enough to undo and redo it
+type change struct {
+	kind   changeKind
+	parent Iparent
+	// the child added or removed, the *CharData, the *Ele of the attr
+	node Node
+	// the index of the child or of the attr
+	index   int
+	oldAttr *Attr
+	newAttr *Attr
+	oldText string
+	newText string
+	oldRoot *Ele
+	newRoot *Ele
+	rootDoc *Doc
+}
+
+// record keeps c in the open transaction of the doc holding p and tells the
+// observers of the doc
+func record(p Iparent, c change) {
+	d, detached := docOf(p)
+	recordIn(d, detached, c)
+}
+
+// recordIn is record for the doc d, detached is true for a change in a subtree
+// that a recorded change of d removed
+func recordIn(d *Doc, detached bool, c change) {
+	if d == nil || !d.hooked() {
+		return
+	}
+	if c.kind == childRemoved && (d.tx != nil || d.replaying || len(d.undo)+len(d.redo) > 0) {
+		// the changes of the removed node still count, they are undone
+		// before it is put back
+		setFrom(c.node, d)
+	}
+	if d.tx != nil {
+		d.tx.log = append(d.tx.log, c)
+	} else if !d.replaying {
+		// the indices the stacks hold may not fit the doc anymore
+		d.undo, d.redo = nil, nil
+	}
+	if len(d.observers) > 0 && !detached {
+		d.notify(c)
+	}
+}
+
+// hooked reports whether d has an open transaction, an undo or redo step or
+// observers, the changes of a doc that has none are not looked at
+func (d *Doc) hooked() bool {
+	return d.tx != nil || len(d.undo) > 0 || len(d.redo) > 0 || len(d.observers) > 0
+}
+
+// docOf returns the doc holding p, nil while p is getting its children from a
+// parse or from its lazy content. for p out of a doc it is the doc a recorded
+// change removed p, or the top of its subtree, from, and detached is true
+func docOf(p Iparent) (d *Doc, detached bool) {
+	e, ok := p.(*Ele)
+	if !ok {
+		d, _ = p.(*Doc)
+		return d, false
+	}
+	if e.loading {
+		return nil, false
+	}
+	for {
+		switch pp := e.parent.(type) {
+		case *Doc:
+			return pp, false
+		case *Ele:
+			e = pp
+		default:
+			return e.from, e.from != nil
+		}
+	}
+}
+
+// setFrom keeps d in n, the only nodes changed out of a doc are the *Ele and
+// the *CharData
+func setFrom(n Node, d *Doc) {
+	switch t := n.(type) {
+	case *Ele:
+		t.from = d
+	case *CharData:
+		t.from = d
+	}
+}
+
+// the primitives below make all the changes that a transaction records and
+// the observers are told about
+
+func pushNode(p Iparent, n Node) {
+	insertNode(p, p.getNodes().Len(), n)
+}
+
+func insertNode(p Iparent, i int, n Node) {
+	setFrom(n, nil)
+	n.setParent(p)
+	p.getNodes().Insert(i, n)
+	record(p, change{kind: childAdded, parent: p, node: n, index: i})
+}
+
+func removeAt(p Iparent, i int) {
+	n := p.getNodes().at(i)
+	p.getNodes().Remove(i)
+	n.setIndex(-1)
+	n.clearParent()
+	record(p, change{kind: childRemoved, parent: p, node: n, index: i})
+}
+
+// removeNodesIf removes the children of p that f returns true for
+func removeNodesIf(p Iparent, f func(n Node) bool) {
+	if d, _ := docOf(p); d == nil || !d.hooked() {
+		p.getNodes().removeIf(f)
+		return
+	}
+	l := p.getNodes()
+	for i := 0; i < l.Len(); {
+		if f((*l)[i]) {
+			removeAt(p, i)
+		} else {
+			i++
+		}
+	}
+}
+
+func setText(cd *CharData, v string) {
+	old := cd.V
+	cd.V = v
+	c := change{kind: textChanged, parent: cd.parent, node: cd, oldText: old, newText: v}
+	if cd.parent != nil {
+		record(cd.parent, c)
+	} else {
+		recordIn(cd.from, true, c)
+	}
+}
+
+func setRoot(d *Doc, e *Ele) {
+	old := d.root
+	d.root = e
+	record(d, change{kind: rootReplaced, parent: d, rootDoc: d, oldRoot: old, newRoot: e})
+}
+
+func insertAttrAt(e *Ele, i int, a *Attr) {
+	e.attrs = slices.Insert(e.attrs, i, a)
+	if e.attrMap != nil || len(e.attrs) > attrMapMin {
+		e.indexAttrs(i)
+	}
+	record(e, change{kind: attrAdded, parent: e, node: e, index: i, newAttr: a})
+}
+
+func replaceAttrAt(e *Ele, i int, a *Attr) {
+	old := e.attrs[i]
+	e.attrs[i] = a
+	if e.attrMap != nil {
+		delete(e.attrMap, old.Name)
+		e.attrMap[a.Name] = i
+	}
+	record(e, change{kind: attrReplaced, parent: e, node: e, index: i, oldAttr: old, newAttr: a})
+}
+
+func deleteAttrAt(e *Ele, i int) {
+	old := e.attrs[i]
+	e.attrs = slices.Delete(e.attrs, i, i+1)
+	if e.attrMap != nil {
+		delete(e.attrMap, old.Name)
+		e.indexAttrs(i)
+	}
+	record(e, change{kind: attrRemoved, parent: e, node: e, index: i, oldAttr: old})
+}
+
+// indexAttrs puts the indexes of the attrs from i on in the attr map, making it
+// if there is none
+func (e *Ele) indexAttrs(i int) {
+	if e.attrMap == nil {
+		e.attrMap = make(map[Name]int, len(e.attrs))
+		i = 0
+	}
+	for ; i < len(e.attrs); i++ {
+		e.attrMap[e.attrs[i].Name] = i
+	}
+}
+
+// apply makes c again, or undoes it
+func (c change) apply(undo bool) {
+	switch c.kind {
+	case childAdded, childRemoved:
+		if (c.kind == childAdded) == undo {
+			removeAt(c.parent, c.index)
+		} else {
+			insertNode(c.parent, c.index, c.node)
+		}
+	case textChanged:
+		if undo {
+			setText(c.node.(*CharData), c.oldText)
+		} else {
+			setText(c.node.(*CharData), c.newText)
+		}
+	case attrAdded, attrRemoved:
+		e := c.node.(*Ele)
+		if (c.kind == attrAdded) == undo {
+			deleteAttrAt(e, c.index)
+		} else if c.kind == attrAdded {
+			insertAttrAt(e, c.index, c.newAttr)
+		} else {
+			insertAttrAt(e, c.index, c.oldAttr)
+		}
+	case attrReplaced:
+		if undo {
+			replaceAttrAt(c.node.(*Ele), c.index, c.oldAttr)
+		} else {
+			replaceAttrAt(c.node.(*Ele), c.index, c.newAttr)
+		}
+	case rootReplaced:
+		if undo {
+			setRoot(c.rootDoc, c.oldRoot)
+		} else {
+			setRoot(c.rootDoc, c.newRoot)
+		}
+	}
+}
+
+// Tx records the mutations made to a doc through the api, see Doc.Begin
+type Tx struct {
+	d   *Doc
+	log []change
+}
+
+// Begin opens a transaction recording every mutation of d: adding, inserting and
+// removing nodes, SetAttr and RemoveAttr, CharData changes and SetRoot, also those
+// made by the operations built on them like ApplyPatch, Walk and MoveBefore.
+// assigning the exported fields, like V and Value, is not recorded.
+// Commit puts the mutations on the undo stack of d, Rollback undoes them
+func (d *Doc) Begin() (*Tx, error) {
+	if d.tx != nil {
+		return nil, ErrTxOpen
+	}
+	if d.frozen {
+		return nil, treeErr("Begin", nil, ErrFrozen)
+	}
+	d.tx = &Tx{d: d}
+	return d.tx, nil
+}
+
+func (t *Tx) close() error {
+	if t.d.tx != t {
+		return ErrTxDone
+	}
+	t.d.tx = nil
+	return nil
+}
+
+// Commit ends the transaction and makes it one step of the undo stack of the doc,
+// the redo stack is cleared
+func (t *Tx) Commit() error {
+	err := t.close()
+	if err != nil {
+		return err
+	}
+	if len(t.log) > 0 {
+		t.d.undo = append(t.d.undo, t.log)
+		t.d.redo = nil
+	}
+	return nil
+}
+
+// Rollback ends the transaction and undoes its mutations, the doc is left with
+// the same nodes in the same order as at Begin
+func (t *Tx) Rollback() error {
+	err := t.close()
+	if err != nil {
+		return err
+	}
+	t.d.replay(t.log, true)
+	return nil
+}
+
+func (d *Doc) replay(log []change, undo bool) {
+	d.replaying = true
+	defer func() {
+		d.replaying = false
+	}()
+	if undo {
+		for i := len(log) - 1; i >= 0; i-- {
+			log[i].apply(true)
+		}
+		return
+	}
+	for _, c := range log {
+		c.apply(false)
+	}
+}
+
+// Undo undoes the last committed transaction of d. a mutation made out of a
+// transaction clears the undo and redo stacks
+func (d *Doc) Undo() error {
+	if d.tx != nil {
+		return ErrTxOpen
+	}
+	if err := frozenErr("Undo", nil, d); err != nil {
+		return err
+	}
+	if len(d.undo) == 0 {
+		return ErrNoChange
+	}
+	log := d.undo[len(d.undo)-1]
+	d.undo = d.undo[:len(d.undo)-1]
+	d.replay(log, true)
+	d.redo = append(d.redo, log)
+	return nil
+}
+
+// Redo makes the last undone transaction of d again
+func (d *Doc) Redo() error {
+	if d.tx != nil {
+		return ErrTxOpen
+	}
+	if err := frozenErr("Redo", nil, d); err != nil {
+		return err
+	}
+	if len(d.redo) == 0 {
+		return ErrNoChange
+	}
+	log := d.redo[len(d.redo)-1]
+	d.redo = d.redo[:len(d.redo)-1]
+	d.replay(log, false)
+	d.undo = append(d.undo, log)
+	return nil
+}
+
+func (d *Doc) CanUndo() bool {
+	return len(d.undo) > 0
+}
+
+func (d *Doc) CanRedo() bool {
+	return len(d.redo) > 0
+}
@@ -0,0 +1,134 @@
+package gdom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTxRollback(t *testing.T) {
+	src := `<beans><bean id="a" class="A">x<property name="p"/>y</bean><!--c--><bean id="b"/></beans>`
+	d, _ := ParseLazy([]byte(src))
+	r := d.Root()
+	a := r.AllEles()[0]
+	b := r.AllEles()[1]
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Begin(); !errors.Is(err, ErrTxOpen) {
+		t.Error("second transaction opened")
+	}
+	a.SetAttr(NewAttr(NewName("", "id"), "z"))
+	a.RemoveAttrByStrName("", "class")
+	a.SetAttr(NewAttr(NewName("", "scope"), "single"))
+	a.RemoveEleByStrName("", "property")
+	MoveBefore(b, a)
+	b.AddCharDataStr("text")
+	r.RemoveAllComment()
+	Wrap(a, NewEle(NewName("", "group"), nil))
+	if d.ToString() == src {
+		t.Error("doc not changed")
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ToString() != src {
+		t.Error("rollback:", d.ToString())
+	}
+	if r.AllEles()[0] != a || r.AllEles()[1] != b || a.Index() != 0 || a.GetParent() != r {
+		t.Error("nodes not restored")
+	}
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+	if tx.Commit() != ErrTxDone {
+		t.Error("commit after rollback")
+	}
+	if d.CanUndo() {
+		t.Error("rolled back tx on the undo stack")
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	src := `<r><a k="1"/>x</r>`
+	d, _ := ParseString(src)
+	tx, _ := d.Begin()
+	d.Root().AllEles()[0].SetAttr(NewAttr(NewName("", "k"), "2"))
+	d.Root().AddCharDataStr("y")
+	tx.Commit()
+	one := d.ToString()
+	tx, _ = d.Begin()
+	root := NewEle(NewName("", "s"), nil)
+	d.SetRoot(root)
+	tx.Commit()
+	two := d.ToString()
+	if one != `<r><a k="2"/>xy</r>` || two != `<s/>` {
+		t.Error("edits:", one, two)
+	}
+	d.Undo()
+	if d.ToString() != one || d.Root().Name.Local != "r" {
+		t.Error("undo root:", d.ToString())
+	}
+	d.Undo()
+	if d.ToString() != `<r><a k="1"/>x</r>` {
+		t.Error("undo:", d.ToString())
+	}
+	if d.Undo() != ErrNoChange || !d.CanRedo() {
+		t.Error("undo stack")
+	}
+	d.Redo()
+	d.Redo()
+	if d.ToString() != two || d.Root() != root {
+		t.Error("redo:", d.ToString())
+	}
+	d.Undo()
+	tx, _ = d.Begin()
+	d.Root().AddCharDataStr("z")
+	tx.Commit()
+	if d.CanRedo() {
+		t.Error("redo stack kept after commit")
+	}
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+	last := d.ToString()
+	d = d.Freeze()
+	if err := d.Undo(); !errors.Is(err, ErrFrozen) || d.ToString() != last {
+		t.Error("undo of a frozen doc:", err)
+	}
+	if err := d.Redo(); !errors.Is(err, ErrFrozen) {
+		t.Error("redo of a frozen doc:", err)
+	}
+}
+
+func TestUndoAfterUntracked(t *testing.T) {
+	d, _ := ParseString(`<r><a/></r>`)
+	tx, _ := d.Begin()
+	d.Root().AddEle(NewEle(NewName("", "b"), nil))
+	tx.Commit()
+	d.Root().RemoveAllNodes()
+	if d.CanUndo() || d.Undo() != ErrNoChange || d.ToString() != `<r/>` {
+		t.Error("undo after a change out of a transaction:", d.ToString())
+	}
+}
+
+func TestRollbackDetached(t *testing.T) {
+	src := `<a><b><c/>x</b></a>`
+	d, _ := ParseString(src)
+	b := d.Root().AllEles()[0]
+	tx, _ := d.Begin()
+	d.Root().RemoveNode(b)
+	b.SetAttr(NewAttr(NewName("", "y"), "2"))
+	c := b.AllEles()[0]
+	b.RemoveNode(c)
+	c.AddCharDataStr("z")
+	b.AddCharDataStr("w")
+	tx.Rollback()
+	if d.ToString() != src || c.ToString() != `<c/>` {
+		t.Error("rollback of detached nodes:", d.ToString(), c.ToString())
+	}
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,147 @@
+package gdom
+
+type actionKind int
+
+const (
+	actContinue actionKind = iota
+	actSkipChildren
+	actStop
+	actRemove
+	actReplace
+)
+
+// Action tells Walk how to go on after visiting a node
+type Action struct {
+	kind  actionKind
+	nodes []Node
+}
+
+var (
+	// walk into the children of the node
+	Continue = Action{kind: actContinue}
+	// don't walk into the children, Leave is still called
+	SkipChildren = Action{kind: actSkipChildren}
+	// end the walk at once
+	Stop = Action{kind: actStop}
+	// remove the node, for Enter its children are not walked and Leave is not called
+	Remove = Action{kind: actRemove}
+)
+
+// Replace the node by copies of nodes, which are not walked.
+// for Enter the children of the node are not walked and Leave is not called
+func Replace(nodes ...Node) Action {
+	return Action{kind: actReplace, nodes: nodes}
+}
+
+// Visitor is called by Walk when entering a node, before its children, and
+// when leaving it, after its children
+type Visitor interface {
+	Enter(n Node) Action
+	Leave(n Node) Action
+}
+
+// VisitorFuncs is a Visitor calling its funcs, a nil func returns Continue
+type VisitorFuncs struct {
+	EnterFunc func(n Node) Action
+	LeaveFunc func(n Node) Action
+}
+
+func (v VisitorFuncs) Enter(n Node) Action {
+	if v.EnterFunc == nil {
+		return Continue
+	}
+	return v.EnterFunc(n)
+}
+
+func (v VisitorFuncs) Leave(n Node) Action {
+	if v.LeaveFunc == nil {
+		return Continue
+	}
+	return v.LeaveFunc(n)
+}
+
+// Walk visits n and the nodes below it in document order.
+// the visitor may change the tree through the actions it returns, or the
+// siblings following the visited node through the tree api
+func Walk(n Node, v Visitor) error {
+	_, err := walk(n, v)
+	return err
+}
+
+// Walk visits all the nodes of the doc in document order, see Walk
+func (d *Doc) Walk(v Visitor) error {
+	_, err := walkChildren(d, v)
+	return err
+}
+
+// walkChildren walks the children of p, it returns false if the walk is stopped
+func walkChildren(p Iparent, v Visitor) (bool, error) {
+	cont := true
+	var err error
+	iterNode(p, func(n Node) bool {
+		cont, err = walk(n, v)
+		return err == nil && cont
+	})
+	return cont, err
+}
+
+// walk returns false if the walk is stopped
+func walk(n Node, v Visitor) (bool, error) {
+	a := v.Enter(n)
+	switch a.kind {
+	case actStop:
+		return false, nil
+	case actRemove, actReplace:
+		return true, applyAction(n, a)
+	}
+	e, ok := n.(*Ele)
+	if ok && a.kind != actSkipChildren {
+		cont, err := walkChildren(e, v)
+		if err != nil || !cont {
+			return cont, err
+		}
+	}
+	a = v.Leave(n)
+	switch a.kind {
+	case actStop:
+		return false, nil
+	case actRemove, actReplace:
+		return true, applyAction(n, a)
+	}
+	return true, nil
+}
+
+func applyAction(n Node, a Action) error {
+	p := n.GetParent()
+	if p == nil {
+		return treeErr("Walk", n, ErrDetached)
+	}
+	d, isDoc := p.(*Doc)
+	isRoot := isDoc && n == Node(d.root)
+	// the root is replaced by one element, the other nodes of a doc by none
+	if isDoc && countEles(a.nodes) != countEles([]Node{n}) {
+		return treeErr("Walk", n, ErrRoot)
+	}
+	if a.kind == actRemove {
+		return removeNode(p, n)
+	}
+	for _, c := range a.nodes {
+		if !checkIsNode(c) {
+			return treeErr("Walk", c, ErrNotNode)
+		}
+	}
+	if err := frozenErr("Walk", n, p); err != nil {
+		return err
+	}
+	// n goes first, a CharData put before it would be merged into it. a marker
+	// keeps its place
+	marker := attach(p, NewComment(""), n)
+	removeNode(p, n)
+	for _, c := range a.nodes {
+		insertBefore(p, c, marker)
+	}
+	if isRoot {
+		setRoot(d, prevSiblingEle(marker))
+	}
+	return removeNode(p, marker)
+}
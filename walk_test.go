@@ -0,0 +1,109 @@
+package gdom
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	d, _ := ParseString(`<beans><old-bean id="a" debug="1"/><wrapper><bean id="b"/>t</wrapper><bean id="c"><!--x--></bean></beans>`)
+	err := d.Walk(VisitorFuncs{
+		EnterFunc: func(n Node) Action {
+			e, ok := n.(*Ele)
+			if !ok {
+				return Continue
+			}
+			e.RemoveAttrByStrName("", "debug")
+			switch e.Name.Local {
+			case "old-bean":
+				ne := e.Copy().(*Ele)
+				ne.Name = NewName("", "bean")
+				return Replace(ne)
+			case "bean":
+				return SkipChildren
+			}
+			return Continue
+		},
+		LeaveFunc: func(n Node) Action {
+			e, ok := n.(*Ele)
+			if ok && e.Name.Local == "wrapper" {
+				return Replace(e.AllNodes()...)
+			}
+			return Continue
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rs := `<beans><bean id="a"/><bean id="b"/>t<bean id="c"><!--x--></bean></beans>`
+	if d.ToString() != rs {
+		t.Error("walk failed", d.ToString())
+	}
+}
+
+func TestWalkStop(t *testing.T) {
+	d, _ := ParseString(`<p><a/><b/><c/></p>`)
+	s := ""
+	Walk(d.Root(), VisitorFuncs{EnterFunc: func(n Node) Action {
+		e := n.(*Ele)
+		s += e.Name.Local
+		if e.Name.Local == "b" {
+			return Stop
+		}
+		return Continue
+	}})
+	if s != "pab" {
+		t.Error("stop failed", s)
+	}
+	err := Walk(d.Root(), VisitorFuncs{EnterFunc: func(n Node) Action {
+		return Remove
+	}})
+	if err == nil {
+		t.Error("removing the root should fail")
+	}
+}
+
+func TestWalkDocRoot(t *testing.T) {
+	src := `<!--c--><?pi x?><r/>`
+	d, _ := ParseString(src)
+	err := d.Walk(VisitorFuncs{EnterFunc: func(n Node) Action {
+		if _, ok := n.(*Comment); ok {
+			return Replace(NewEle(NewName("", "s"), nil))
+		}
+		return Continue
+	}})
+	if !errors.Is(err, ErrRoot) || d.ToString() != src {
+		t.Error("second root:", err, d.ToString())
+	}
+	err = d.Walk(VisitorFuncs{EnterFunc: func(n Node) Action {
+		if _, ok := n.(*ProcInst); ok {
+			return Replace(NewComment("p"))
+		}
+		return Continue
+	}})
+	if err != nil || d.ToString() != `<!--c--><!--p--><r/>` {
+		t.Error("replace in the prolog:", err, d.ToString())
+	}
+	if err := d.Check(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWalkReplaceText(t *testing.T) {
+	d, _ := ParseString(`<r>old<a/>x</r>`)
+	err := d.Walk(VisitorFuncs{EnterFunc: func(n Node) Action {
+		switch n := n.(type) {
+		case *CharData:
+			return Replace(NewCharData("new" + n.V))
+		case *Ele:
+			if n.Name.Local == "a" {
+				return Replace(NewCharData("-"))
+			}
+		}
+		return Continue
+	}})
+	if err != nil || d.ToString() != `<r>newold-newx</r>` {
+		t.Error("replace text:", err, d.ToString())
+	}
+}